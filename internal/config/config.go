@@ -2,14 +2,69 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"kasir-api/internal/domain"
 
 	"github.com/spf13/viper"
 )
 
+// Checkout concurrency strategies accepted by CHECKOUT_STRATEGY.
+const (
+	// CheckoutStrategyLock locks all product rows of a checkout with SELECT ... FOR UPDATE.
+	CheckoutStrategyLock = "lock"
+	// CheckoutStrategyOptimistic uses conditional stock updates and retries on serialization failures.
+	CheckoutStrategyOptimistic = "optimistic"
+)
+
+// Service charge modes accepted by SERVICE_CHARGE_MODE.
+const (
+	// ServiceChargeBeforeTax charges service on the pre-tax amount; the service charge is then taxed.
+	ServiceChargeBeforeTax = "before_tax"
+	// ServiceChargeAfterTax charges service on the amount including tax.
+	ServiceChargeAfterTax = "after_tax"
+)
+
 // Config holds application configuration.
 type Config struct {
 	DBConn string
 	Port   string
+
+	CheckoutStrategy   string
+	CheckoutMaxRetries int
+	IdempotencyTTL     time.Duration
+
+	TaxRate             float64 // percent, e.g. 11 for PPN 11%
+	TaxInclusive        bool    // product prices already include tax
+	TaxExemptCategories []int
+	ServiceChargeRate   float64 // percent
+	ServiceChargeMode   string
+
+	LowStockThreshold int // quotes warn when a sale leaves this much stock or less
+
+	CartTTL          time.Duration // how long an untouched parked cart stays open
+	CartReserveStock bool          // default for reserving stock on new carts
+
+	StoreName          string
+	StoreAddress       string
+	StorePhone         string
+	StoreNPWP          string
+	ReceiptFooter      string
+	ReceiptPaperWidth  int    // millimetres, 58 or 80
+	ReceiptLogo        string // path to a PNG or JPEG logo for HTML and PDF receipts
+	ReceiptTemplateDir string // directory with a receipt.html overriding the built-in template
+	ReceiptBaseURL     string // public URL of the API, encoded in receipt QR codes
+
+	AdminToken string // required in X-Admin-Token for admin endpoints; empty disables them
+
+	OutletCode           string
+	ReceiptNumberPattern string // e.g. INV/{YYYY}/{MM}/{DD}/{SEQ:4}, see domain.ReceiptNumbering
+	ReceiptNumberReset   string // daily, monthly, yearly or never
+
+	InStoreBarcodes []domain.InStoreBarcodeLayout // scale label layouts, see domain.ParseInStoreBarcodeLayouts
 }
 
 // Load reads configuration from .env and environment variables.
@@ -21,12 +76,64 @@ func Load() (*Config, error) {
 	viper.AddConfigPath(".")
 	viper.AutomaticEnv()
 
+	viper.SetDefault("CHECKOUT_STRATEGY", CheckoutStrategyLock)
+	viper.SetDefault("CHECKOUT_MAX_RETRIES", 5)
+	viper.SetDefault("IDEMPOTENCY_TTL", "24h")
+	viper.SetDefault("TAX_RATE", 0)
+	viper.SetDefault("TAX_INCLUSIVE", false)
+	viper.SetDefault("SERVICE_CHARGE_RATE", 0)
+	viper.SetDefault("SERVICE_CHARGE_MODE", ServiceChargeBeforeTax)
+	viper.SetDefault("LOW_STOCK_THRESHOLD", 5)
+	viper.SetDefault("CART_TTL", "30m")
+	viper.SetDefault("CART_RESERVE_STOCK", false)
+	viper.SetDefault("STORE_NAME", "Kasir")
+	viper.SetDefault("RECEIPT_FOOTER", "Terima kasih")
+	viper.SetDefault("RECEIPT_PAPER_WIDTH", 58)
+	viper.SetDefault("RECEIPT_NUMBER_PATTERN", "INV/{YYYY}/{MM}/{DD}/{SEQ:4}")
+	viper.SetDefault("RECEIPT_NUMBER_RESET", domain.ReceiptResetDaily)
+	viper.SetDefault("INSTORE_BARCODE_LAYOUTS", "20:5:weight,21:5:price")
+
 	_ = viper.ReadInConfig() // ignore file-not-found; env vars still work
 
 	cfg := &Config{
-		DBConn: viper.GetString("DB_CONN"),
-		Port:   viper.GetString("PORT"),
+		DBConn:               viper.GetString("DB_CONN"),
+		Port:                 viper.GetString("PORT"),
+		CheckoutStrategy:     viper.GetString("CHECKOUT_STRATEGY"),
+		CheckoutMaxRetries:   viper.GetInt("CHECKOUT_MAX_RETRIES"),
+		IdempotencyTTL:       viper.GetDuration("IDEMPOTENCY_TTL"),
+		TaxRate:              viper.GetFloat64("TAX_RATE"),
+		TaxInclusive:         viper.GetBool("TAX_INCLUSIVE"),
+		ServiceChargeRate:    viper.GetFloat64("SERVICE_CHARGE_RATE"),
+		ServiceChargeMode:    viper.GetString("SERVICE_CHARGE_MODE"),
+		LowStockThreshold:    viper.GetInt("LOW_STOCK_THRESHOLD"),
+		CartTTL:              viper.GetDuration("CART_TTL"),
+		CartReserveStock:     viper.GetBool("CART_RESERVE_STOCK"),
+		StoreName:            viper.GetString("STORE_NAME"),
+		StoreAddress:         viper.GetString("STORE_ADDRESS"),
+		StorePhone:           viper.GetString("STORE_PHONE"),
+		StoreNPWP:            viper.GetString("STORE_NPWP"),
+		ReceiptFooter:        viper.GetString("RECEIPT_FOOTER"),
+		ReceiptPaperWidth:    viper.GetInt("RECEIPT_PAPER_WIDTH"),
+		ReceiptLogo:          viper.GetString("RECEIPT_LOGO"),
+		ReceiptTemplateDir:   viper.GetString("RECEIPT_TEMPLATE_DIR"),
+		ReceiptBaseURL:       viper.GetString("RECEIPT_BASE_URL"),
+		AdminToken:           viper.GetString("ADMIN_TOKEN"),
+		OutletCode:           viper.GetString("OUTLET_CODE"),
+		ReceiptNumberPattern: viper.GetString("RECEIPT_NUMBER_PATTERN"),
+		ReceiptNumberReset:   viper.GetString("RECEIPT_NUMBER_RESET"),
+	}
+
+	exempt, err := parseIntList(viper.GetString("TAX_EXEMPT_CATEGORIES"))
+	if err != nil {
+		return nil, fmt.Errorf("TAX_EXEMPT_CATEGORIES: %w", err)
+	}
+	cfg.TaxExemptCategories = exempt
+
+	layouts, err := domain.ParseInStoreBarcodeLayouts(viper.GetString("INSTORE_BARCODE_LAYOUTS"))
+	if err != nil {
+		return nil, fmt.Errorf("INSTORE_BARCODE_LAYOUTS: %w", err)
 	}
+	cfg.InStoreBarcodes = layouts
 
 	if cfg.Port == "" {
 		cfg.Port = "8080"
@@ -34,5 +141,68 @@ func Load() (*Config, error) {
 	if cfg.DBConn == "" {
 		return nil, errors.New("DB_CONN is required (set in .env or environment)")
 	}
+	if cfg.CheckoutStrategy != CheckoutStrategyLock && cfg.CheckoutStrategy != CheckoutStrategyOptimistic {
+		return nil, fmt.Errorf("CHECKOUT_STRATEGY must be %q or %q, got %q",
+			CheckoutStrategyLock, CheckoutStrategyOptimistic, cfg.CheckoutStrategy)
+	}
+	if cfg.CheckoutMaxRetries < 0 {
+		return nil, errors.New("CHECKOUT_MAX_RETRIES must not be negative")
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		return nil, errors.New("IDEMPOTENCY_TTL must be a positive duration (e.g. 24h)")
+	}
+	if cfg.TaxRate < 0 || cfg.TaxRate > 100 {
+		return nil, errors.New("TAX_RATE must be a percentage between 0 and 100")
+	}
+	if cfg.ServiceChargeRate < 0 || cfg.ServiceChargeRate > 100 {
+		return nil, errors.New("SERVICE_CHARGE_RATE must be a percentage between 0 and 100")
+	}
+	if cfg.ServiceChargeMode != ServiceChargeBeforeTax && cfg.ServiceChargeMode != ServiceChargeAfterTax {
+		return nil, fmt.Errorf("SERVICE_CHARGE_MODE must be %q or %q, got %q",
+			ServiceChargeBeforeTax, ServiceChargeAfterTax, cfg.ServiceChargeMode)
+	}
+	if cfg.LowStockThreshold < 0 {
+		return nil, errors.New("LOW_STOCK_THRESHOLD must not be negative")
+	}
+	if cfg.CartTTL <= 0 {
+		return nil, errors.New("CART_TTL must be a positive duration (e.g. 30m)")
+	}
+	if cfg.ReceiptPaperWidth != 58 && cfg.ReceiptPaperWidth != 80 {
+		return nil, fmt.Errorf("RECEIPT_PAPER_WIDTH must be 58 or 80, got %d", cfg.ReceiptPaperWidth)
+	}
+	if err := cfg.ReceiptNumbering().Validate(); err != nil {
+		return nil, fmt.Errorf("RECEIPT_NUMBER_PATTERN/RECEIPT_NUMBER_RESET: %w", err)
+	}
 	return cfg, nil
 }
+
+// parseIntList parses a comma-separated list of integers such as "3,7". Empty means none.
+func parseIntList(v string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", part)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// CheckoutUseLock reports whether checkout should use pessimistic row locking.
+func (c *Config) CheckoutUseLock() bool {
+	return c.CheckoutStrategy == CheckoutStrategyLock
+}
+
+// ReceiptNumbering returns how transactions are numbered.
+func (c *Config) ReceiptNumbering() domain.ReceiptNumbering {
+	return domain.ReceiptNumbering{
+		Pattern: c.ReceiptNumberPattern,
+		Reset:   c.ReceiptNumberReset,
+		Outlet:  c.OutletCode,
+	}
+}
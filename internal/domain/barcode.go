@@ -0,0 +1,54 @@
+package domain
+
+// ValidBarcode reports whether code is a well-formed EAN-8, UPC-E, UPC-A or EAN-13 barcode with a
+// correct check digit.
+func ValidBarcode(code string) bool {
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	switch len(code) {
+	case 8:
+		return gtinCheckDigitOK(code) || upcECheckDigitOK(code)
+	case 12, 13:
+		return gtinCheckDigitOK(code)
+	}
+	return false
+}
+
+// gtinCheckDigitOK verifies the GS1 mod-10 check digit of an all-digit code: from the right,
+// digits are weighted 3, 1, 3, ... starting with the one next to the check digit.
+func gtinCheckDigitOK(code string) bool {
+	n := len(code)
+	sum := 0
+	for i := n - 2; i >= 0; i-- {
+		d := int(code[i] - '0')
+		if (n-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10-sum%10)%10 == int(code[n-1]-'0')
+}
+
+// upcECheckDigitOK verifies an 8-digit UPC-E code, whose check digit is that of the UPC-A code it
+// expands to.
+func upcECheckDigitOK(code string) bool {
+	if code[0] != '0' && code[0] != '1' {
+		return false
+	}
+	d := code[1:7]
+	var body string
+	switch d[5] {
+	case '0', '1', '2':
+		body = d[0:2] + d[5:6] + "0000" + d[2:5]
+	case '3':
+		body = d[0:3] + "00000" + d[3:5]
+	case '4':
+		body = d[0:4] + "00000" + d[4:5]
+	default:
+		body = d[0:5] + "0000" + d[5:6]
+	}
+	return gtinCheckDigitOK(code[0:1] + body + code[7:8])
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "testing"
+
+func TestValidBarcode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"4006381333931", true},  // EAN-13
+		{"4006381333932", false}, // EAN-13, wrong check digit
+		{"036000291452", true},   // UPC-A
+		{"036000291453", false},  // UPC-A, wrong check digit
+		{"96385074", true},       // EAN-8
+		{"96385075", false},      // EAN-8, wrong check digit
+		{"04252614", true},       // UPC-E of 042100005264
+		{"04252615", false},      // UPC-E, wrong check digit
+		{"400638133393A", false}, // not all digits
+		{"1234567", false},       // no such length
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidBarcode(tt.code); got != tt.want {
+			t.Errorf("ValidBarcode(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package domain
+
+// BundleComponent is a line of a bundle's bill of materials: one base unit of the bundle uses
+// Quantity of the component, in the component's base unit. On a TransactionDetail it is instead
+// the total the line took from the component's stock.
+type BundleComponent struct {
+	ProductID   int      `json:"product_id"`
+	ProductName string   `json:"product_name,omitempty"`
+	Quantity    Quantity `json:"quantity"`
+}
+
+// IsBundle reports whether p is made of components, whose stock is used when p is sold.
+func (p Product) IsBundle() bool {
+	return len(p.Components) > 0
+}
+
+// StockUsage returns what the line takes from stock, by product in base units: the components for
+// a bundle, otherwise the line's own product.
+func (d TransactionDetail) StockUsage() []BundleComponent {
+	if len(d.Components) > 0 {
+		return d.Components
+	}
+	return []BundleComponent{{ProductID: d.ProductID, ProductName: d.ProductName, Quantity: d.BaseQuantity()}}
+}
@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+// Cart statuses.
+const (
+	CartStatusOpen       = "open"
+	CartStatusCheckedOut = "checked_out"
+	CartStatusExpired    = "expired"
+	CartStatusCancelled  = "cancelled"
+)
+
+// Cart is a parked sale that can be resumed and checked out later. When ReserveStock is set,
+// the quantities in the cart are held back from stock until it is checked out, cancelled or expires.
+type Cart struct {
+	ID            int        `json:"id"`
+	Status        string     `json:"status"`
+	ReserveStock  bool       `json:"reserve_stock"`
+	Discount      *Discount  `json:"discount,omitempty"`
+	Items         []CartItem `json:"items"`
+	TransactionID int        `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+}
+
+// CartItem is one product line of a cart. Quantity is in Unit (empty for the base unit), while
+// ReservedQuantity is in the product's base unit. Modifiers are the sorted IDs of the modifiers
+// picked for the line.
+type CartItem struct {
+	ID               int       `json:"id"`
+	CartID           int       `json:"cart_id"`
+	ProductID        int       `json:"product_id"`
+	ProductName      string    `json:"product_name,omitempty"`
+	Quantity         Quantity  `json:"quantity"`
+	Unit             string    `json:"unit,omitempty"`
+	Modifiers        []int     `json:"modifiers,omitempty"`
+	Discount         *Discount `json:"discount,omitempty"`
+	ReservedQuantity Quantity  `json:"reserved_quantity"`
+}
+
+// CreateCartRequest is the body for POST /api/carts. ReserveStock defaults to the server setting.
+type CreateCartRequest struct {
+	Items        []CheckoutItem `json:"items"`
+	Discount     *Discount      `json:"discount,omitempty"`
+	ReserveStock *bool          `json:"reserve_stock,omitempty"`
+}
+
+// CartCheckoutRequest is the body for POST /api/carts/{id}/checkout.
+type CartCheckoutRequest struct {
+	Payment  *PaymentInput  `json:"payment,omitempty"`
+	Payments []PaymentInput `json:"payments,omitempty"`
+}
@@ -1,7 +1,84 @@
 package domain
 
+import "slices"
+
 // Category is the domain entity for a product category.
+// ParentID is nil for a top-level category.
 type Category struct {
-	ID   int    `json:"id"`
-	Nama string `json:"nama"`
+	ID       int    `json:"id"`
+	Nama     string `json:"nama"`
+	ParentID *int   `json:"parent_id,omitempty"`
+}
+
+// CategoryNode is a category with its subcategories, as returned by GET /api/categories?tree=true.
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// CategoryTree arranges cats into nested nodes. Categories whose parent is not in cats become
+// top-level nodes; siblings keep the order they have in cats.
+func CategoryTree(cats []Category) []CategoryNode {
+	present := make(map[int]bool, len(cats))
+	for _, c := range cats {
+		present[c.ID] = true
+	}
+	children := make(map[int][]Category)
+	var roots []Category
+	for _, c := range cats {
+		if c.ParentID == nil || !present[*c.ParentID] {
+			roots = append(roots, c)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	var build func(list []Category) []CategoryNode
+	build = func(list []Category) []CategoryNode {
+		nodes := make([]CategoryNode, 0, len(list))
+		for _, c := range list {
+			nodes = append(nodes, CategoryNode{Category: c, Children: build(children[c.ID])})
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+// CategoryDescendants returns id followed by the IDs of every category below it in cats.
+func CategoryDescendants(cats []Category, id int) []int {
+	children := make(map[int][]int)
+	for _, c := range cats {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+	out := []int{id}
+	seen := map[int]bool{id: true}
+	for i := 0; i < len(out); i++ {
+		for _, child := range children[out[i]] {
+			if !seen[child] {
+				seen[child] = true
+				out = append(out, child)
+			}
+		}
+	}
+	return out
+}
+
+// CategoryAncestors returns id followed by the IDs of the categories above it in cats, nearest first.
+func CategoryAncestors(cats []Category, id int) []int {
+	parents := make(map[int]int)
+	for _, c := range cats {
+		if c.ParentID != nil {
+			parents[c.ID] = *c.ParentID
+		}
+	}
+	out := []int{id}
+	for {
+		parent, ok := parents[out[len(out)-1]]
+		if !ok || slices.Contains(out, parent) {
+			return out
+		}
+		out = append(out, parent)
+	}
 }
@@ -0,0 +1,21 @@
+package domain
+
+// Discount types.
+const (
+	DiscountTypePercent = "percent"
+	DiscountTypeFixed   = "fixed"
+)
+
+// Discount is a discount requested at checkout: Value percent of the amount, or Value rupiah off.
+type Discount struct {
+	Type  string `json:"type"`
+	Value int    `json:"value"`
+}
+
+// Amount returns the rupiah discount on base. Percentages are rounded down.
+func (d Discount) Amount(base int) int {
+	if d.Type == DiscountTypePercent {
+		return base * d.Value / 100
+	}
+	return d.Value
+}
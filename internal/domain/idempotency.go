@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// IdempotencyRecord is a stored Idempotency-Key for checkout. Transaction is nil while the
+// original request is still being processed.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	Transaction *Transaction
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
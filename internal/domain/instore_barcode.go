@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// In-store barcode kinds: what the value encoded in a scale label means.
+const (
+	InStoreBarcodeWeight = "weight" // grams; the product is priced per kg
+	InStoreBarcodePrice  = "price"  // rupiah for the whole label
+)
+
+// InStoreBarcodeLayout describes EAN-13 labels printed by in-store scales: Prefix (starting with 2),
+// then ItemDigits digits identifying the item, then the value up to the check digit.
+//
+// A product is linked to such labels by giving it the barcode the label would have with a value of
+// zero (see Base).
+type InStoreBarcodeLayout struct {
+	Prefix     string
+	ItemDigits int
+	Kind       string
+}
+
+// ParseInStoreBarcodeLayouts parses a comma-separated list of PREFIX:ITEM_DIGITS:KIND layouts such
+// as "20:5:weight,21:5:price". Empty means none.
+func ParseInStoreBarcodeLayouts(s string) ([]InStoreBarcodeLayout, error) {
+	var out []InStoreBarcodeLayout
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("layout %q must be PREFIX:ITEM_DIGITS:KIND", part)
+		}
+		digits, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("layout %q: invalid item digits", part)
+		}
+		l := InStoreBarcodeLayout{Prefix: fields[0], ItemDigits: digits, Kind: fields[2]}
+		if err := l.Validate(); err != nil {
+			return nil, fmt.Errorf("layout %q: %w", part, err)
+		}
+		for _, other := range out {
+			if strings.HasPrefix(l.Prefix, other.Prefix) || strings.HasPrefix(other.Prefix, l.Prefix) {
+				return nil, fmt.Errorf("layout %q overlaps prefix %s", part, other.Prefix)
+			}
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// Validate checks that the layout leaves room for at least one value digit in an EAN-13.
+func (l InStoreBarcodeLayout) Validate() error {
+	if l.Prefix == "" || l.Prefix[0] != '2' {
+		return fmt.Errorf("prefix must start with 2")
+	}
+	for _, r := range l.Prefix {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("prefix must be digits")
+		}
+	}
+	if l.ItemDigits < 1 || l.valueDigits() < 1 {
+		return fmt.Errorf("prefix and item digits must leave room for the value in 12 digits")
+	}
+	if l.Kind != InStoreBarcodeWeight && l.Kind != InStoreBarcodePrice {
+		return fmt.Errorf("kind must be %q or %q", InStoreBarcodeWeight, InStoreBarcodePrice)
+	}
+	return nil
+}
+
+func (l InStoreBarcodeLayout) valueDigits() int {
+	return 12 - len(l.Prefix) - l.ItemDigits
+}
+
+// Decode splits a label printed with this layout into the barcode of its product (see Base) and
+// the encoded value. ok is false if code is not a valid EAN-13 with this layout's prefix.
+func (l InStoreBarcodeLayout) Decode(code string) (base string, value int, ok bool) {
+	if len(code) != 13 || !strings.HasPrefix(code, l.Prefix) || !ValidBarcode(code) {
+		return "", 0, false
+	}
+	itemEnd := len(l.Prefix) + l.ItemDigits
+	value, err := strconv.Atoi(code[itemEnd:12])
+	if err != nil {
+		return "", 0, false
+	}
+	return l.Base(code[len(l.Prefix):itemEnd]), value, true
+}
+
+// Base returns the barcode of item with a value of zero, which is how products are linked to labels.
+func (l InStoreBarcodeLayout) Base(item string) string {
+	body := l.Prefix + item + strings.Repeat("0", l.valueDigits())
+	for d := byte('0'); d <= '9'; d++ {
+		if code := body + string(d); gtinCheckDigitOK(code) {
+			return code
+		}
+	}
+	return ""
+}
+
+// ScannedProduct is the product behind a scanned barcode. For an in-store scale label, Quantity is
+// the quantity the label stands for and Amount, for price labels, the rupiah value printed on it.
+type ScannedProduct struct {
+	Product
+	Quantity Quantity `json:"quantity,omitempty"`
+	Amount   int      `json:"amount,omitempty"`
+}
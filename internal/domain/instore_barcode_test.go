@@ -0,0 +1,33 @@
+package domain
+
+import "testing"
+
+func TestInStoreBarcodeDecode(t *testing.T) {
+	weight := InStoreBarcodeLayout{Prefix: "20", ItemDigits: 5, Kind: InStoreBarcodeWeight}
+	price := InStoreBarcodeLayout{Prefix: "21", ItemDigits: 5, Kind: InStoreBarcodePrice}
+
+	tests := []struct {
+		layout    InStoreBarcodeLayout
+		code      string
+		wantBase  string
+		wantValue int
+		wantOK    bool
+	}{
+		{weight, "2012345004535", "2012345000001", 453, true}, // item 12345, 453 g
+		{price, "2112345015002", "2112345000008", 1500, true}, // item 12345, Rp 1.500
+		{weight, "2012345004536", "", 0, false},               // wrong check digit
+		{weight, "2112345015002", "", 0, false},               // another layout's prefix
+		{weight, "201234500453", "", 0, false},                // not an EAN-13
+	}
+	for _, tt := range tests {
+		base, value, ok := tt.layout.Decode(tt.code)
+		if base != tt.wantBase || value != tt.wantValue || ok != tt.wantOK {
+			t.Errorf("%s layout, Decode(%q) = %q, %d, %v; want %q, %d, %v",
+				tt.layout.Kind, tt.code, base, value, ok, tt.wantBase, tt.wantValue, tt.wantOK)
+		}
+	}
+
+	if got := weight.Base("12345"); got != "2012345000001" {
+		t.Errorf("Base(12345) = %q, want 2012345000001", got)
+	}
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "slices"
+
+// ModifierGroup is a set of options that can be added to an order line, such as "Shot" with
+// "Extra shot" or "Gula" with "Less sugar". It applies to the products in ProductIDs, their
+// variants, and the products of the categories in CategoryIDs and of their subcategories. A line
+// must pick at least MinSelect and at most MaxSelect options of every group that applies;
+// MaxSelect 0 means no limit.
+type ModifierGroup struct {
+	ID          int        `json:"id"`
+	Nama        string     `json:"nama"`
+	MinSelect   int        `json:"min_select"`
+	MaxSelect   int        `json:"max_select"`
+	Options     []Modifier `json:"options"`
+	ProductIDs  []int      `json:"product_ids"`
+	CategoryIDs []int      `json:"category_ids"`
+}
+
+// Modifier is one option of a modifier group. Harga is added to the unit price of the line it is
+// picked on and may be zero, e.g. for "less sugar".
+type Modifier struct {
+	ID    int    `json:"id"`
+	Nama  string `json:"nama"`
+	Harga int    `json:"harga"`
+}
+
+// AppliesTo reports whether lines of p offer the group's options. categoryIDs are p's category and
+// the categories above it, as returned by CategoryAncestors.
+func (g ModifierGroup) AppliesTo(p Product, categoryIDs []int) bool {
+	if slices.Contains(g.ProductIDs, p.ID) || slices.ContainsFunc(categoryIDs, func(id int) bool {
+		return slices.Contains(g.CategoryIDs, id)
+	}) {
+		return true
+	}
+	return p.ParentID != nil && slices.Contains(g.ProductIDs, *p.ParentID)
+}
+
+// DetailModifier is a modifier as sold on a transaction line, snapshotted like the line's product.
+type DetailModifier struct {
+	ModifierID int    `json:"modifier_id"`
+	Group      string `json:"group"`
+	Nama       string `json:"nama"`
+	Harga      int    `json:"harga"`
+}
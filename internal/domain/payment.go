@@ -0,0 +1,36 @@
+package domain
+
+// Payment methods accepted at checkout.
+const (
+	PaymentMethodCash     = "cash"
+	PaymentMethodDebit    = "debit"
+	PaymentMethodQRIS     = "qris"
+	PaymentMethodTransfer = "transfer"
+)
+
+// ValidPaymentMethod reports whether m is a known payment method.
+func ValidPaymentMethod(m string) bool {
+	switch m {
+	case PaymentMethodCash, PaymentMethodDebit, PaymentMethodQRIS, PaymentMethodTransfer:
+		return true
+	}
+	return false
+}
+
+// PaymentInput is one tender of a checkout request. Amount is what the customer tendered; a lone
+// non-cash tender may omit it to settle the whole transaction total.
+type PaymentInput struct {
+	Method string `json:"method"`
+	Amount int    `json:"amount"`
+}
+
+// Payment is a payment recorded for a transaction. Amount is the part of the total it settles;
+// Tendered minus Amount is the Change handed back.
+type Payment struct {
+	ID            int    `json:"id"`
+	TransactionID int    `json:"transaction_id"`
+	Method        string `json:"method"`
+	Amount        int    `json:"amount"`
+	Tendered      int    `json:"tendered"`
+	Change        int    `json:"change"`
+}
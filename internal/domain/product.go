@@ -1,11 +1,73 @@
 package domain
 
 // Product is the domain entity for a product.
+// HargaPokok is the unit cost used for cost of goods sold. Inactive products are hidden from the
+// product list by default and cannot be sold. SKU and Barcodes are unique across products.
+// Stok may be fractional for products sold by weight.
+//
+// Unit is the base unit: Harga, HargaPokok and Stok are per base unit. Units are further units
+// the product is sold in, each holding a number of base units.
+//
+// A product with VariantAttributes is sold only through its variants, which are products of their
+// own with ParentID set and a value for each of the parent's attributes. See variant.go.
+//
+// A product with Components is a bundle: selling it takes stock from the components instead, and
+// its Stok is how many bundles the components make up. See bundle.go.
 type Product struct {
-	ID       int      `json:"id"`
-	Nama     string   `json:"nama"`
-	Harga    int      `json:"harga"`
-	Stok     int      `json:"stok"`
-	Active   bool     `json:"active"`
-	Category Category `json:"category"`
+	ID         int           `json:"id"`
+	Nama       string        `json:"nama"`
+	SKU        string        `json:"sku,omitempty"`
+	Barcodes   []string      `json:"barcodes,omitempty"`
+	Harga      int           `json:"harga"`
+	HargaPokok int           `json:"harga_pokok"`
+	Stok       Quantity      `json:"stok"`
+	Unit       string        `json:"unit"`
+	Units      []ProductUnit `json:"units,omitempty"`
+	Active     bool          `json:"active"`
+	Category   Category      `json:"category"`
+
+	ParentID          *int              `json:"parent_id,omitempty"`
+	VariantAttributes []string          `json:"variant_attributes,omitempty"`
+	Attributes        map[string]string `json:"attributes,omitempty"`
+	Variants          []Product         `json:"variants,omitempty"`
+
+	Components []BundleComponent `json:"components,omitempty"`
+}
+
+// DefaultUnit is the base unit of products that do not name one.
+const DefaultUnit = "pcs"
+
+// ProductUnit is a unit a product is sold in besides its base unit, e.g. a 25 kg sack of rice
+// sold per kg. Factor is how many base units one of it holds and Harga is its own price.
+type ProductUnit struct {
+	Name   string   `json:"name"`
+	Factor Quantity `json:"factor"`
+	Harga  int      `json:"harga"`
+}
+
+// SellingUnit returns the unit called name: the base unit, with a factor of 1 and the product's
+// price, for "" or p.Unit, otherwise one of p.Units. ok is false if the product has no such unit.
+func (p Product) SellingUnit(name string) (u ProductUnit, ok bool) {
+	if name == "" || name == p.Unit {
+		return ProductUnit{Name: p.Unit, Factor: Units(1), Harga: p.Harga}, true
+	}
+	for _, u := range p.Units {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return ProductUnit{}, false
+}
+
+// ProductFilter narrows GET /api/products. Name matches a substring, case-insensitively.
+// CategoryID selects a category together with all its subcategories; the use case expands it into
+// CategoryIDs, which is what repositories filter on. ParentID selects the variants of a product and
+// ComponentID the bundles that use a product.
+type ProductFilter struct {
+	Name            string
+	IncludeInactive bool
+	CategoryID      int
+	CategoryIDs     []int
+	ParentID        int
+	ComponentID     int
 }
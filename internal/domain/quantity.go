@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Quantity is an amount of a product with up to three decimal places, such as 2 pieces or 0.453 kg.
+// It counts thousandths so arithmetic stays exact. In JSON it is a plain number and in PostgreSQL a
+// NUMERIC(12,3).
+type Quantity int64
+
+// quantityScale is the number of Quantity steps in one unit.
+const quantityScale = 1000
+
+// Units returns a Quantity of n whole units.
+func Units(n int) Quantity {
+	return Quantity(n) * quantityScale
+}
+
+// Thousandths returns a Quantity of n thousandths of a unit, e.g. grams of a product sold per kg.
+func Thousandths(n int) Quantity {
+	return Quantity(n)
+}
+
+// ParseQuantity parses a decimal such as "2", "0.5" or "1.250". More than three decimal places is an error.
+func ParseQuantity(s string) (Quantity, error) {
+	neg := strings.HasPrefix(s, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	if len(frac) > 3 {
+		return 0, fmt.Errorf("quantity %q has more than 3 decimal places", s)
+	}
+	for _, part := range []string{whole, frac} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, fmt.Errorf("invalid quantity %q", s)
+			}
+		}
+	}
+	var q int64
+	if whole != "" {
+		n, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || n > (1<<62)/quantityScale {
+			return 0, fmt.Errorf("quantity %q out of range", s)
+		}
+		q = n * quantityScale
+	}
+	if frac != "" {
+		n, _ := strconv.ParseInt((frac + "00")[:3], 10, 64)
+		q += n
+	}
+	if neg {
+		q = -q
+	}
+	return Quantity(q), nil
+}
+
+// String formats q without trailing zeros, e.g. "2" or "0.453".
+func (q Quantity) String() string {
+	sign := ""
+	n := int64(q)
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	whole, frac := n/quantityScale, n%quantityScale
+	if frac == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+	return sign + strconv.FormatInt(whole, 10) + "." + strings.TrimRight(fmt.Sprintf("%03d", frac), "0")
+}
+
+// IsWhole reports whether q has no fractional part.
+func (q Quantity) IsWhole() bool {
+	return q%quantityScale == 0
+}
+
+// Price returns the rupiah value of q at unitPrice per unit, rounded half up.
+func (q Quantity) Price(unitPrice int) int {
+	return int(roundDiv(int64(q)*int64(unitPrice), quantityScale))
+}
+
+// Mul returns q times n, rounded half up to three decimal places.
+func (q Quantity) Mul(n Quantity) Quantity {
+	return Quantity(roundDiv(int64(q)*int64(n), quantityScale))
+}
+
+// roundDiv divides a by positive b, rounding halves away from zero.
+func roundDiv(a, b int64) int64 {
+	if a < 0 {
+		return -((-a + b/2) / b)
+	}
+	return (a + b/2) / b
+}
+
+// MarshalJSON encodes q as a JSON number.
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number with up to three decimal places.
+func (q *Quantity) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid quantity %s", s)
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	v, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = v
+	return nil
+}
+
+// Value stores q as a decimal string for a NUMERIC column.
+func (q Quantity) Value() (driver.Value, error) {
+	return q.String(), nil
+}
+
+// Scan reads a NUMERIC column. NULL reads as zero.
+func (q *Quantity) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*q = 0
+	case int64:
+		*q = Units(int(v))
+	case string:
+		return q.scanString(v)
+	case []byte:
+		return q.scanString(string(v))
+	default:
+		return errors.New("quantity: unsupported column type")
+	}
+	return nil
+}
+
+func (q *Quantity) scanString(s string) error {
+	// NUMERIC(12,3) never has more than three decimals, but sums of it may print trailing zeros.
+	if whole, frac, ok := strings.Cut(s, "."); ok && len(frac) > 3 {
+		s = whole + "." + strings.TrimRight(frac, "0")
+	}
+	v, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = v
+	return nil
+}
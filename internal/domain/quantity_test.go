@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Quantity
+		wantErr bool
+	}{
+		{in: "2", want: Units(2)},
+		{in: "0.5", want: Thousandths(500)},
+		{in: "1.250", want: Thousandths(1250)},
+		{in: "0.453", want: Thousandths(453)},
+		{in: ".5", want: Thousandths(500)},
+		{in: "3.", want: Units(3)},
+		{in: "-1.5", want: Thousandths(-1500)},
+		{in: "1.2345", wantErr: true},
+		{in: "", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "1,5", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuantity(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseQuantity(%q) = %s, %v; want %s, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestQuantityUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Quantity
+		wantErr bool
+	}{
+		{in: `2`, want: Units(2)},
+		{in: `1.5`, want: Thousandths(1500)},
+		{in: `0.453`, want: Thousandths(453)},
+		{in: `1e3`, want: Units(1000)},
+		{in: `2.5E-1`, want: Thousandths(250)},
+		{in: `0.0005`, wantErr: true},
+		{in: `"2"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got struct {
+			Q Quantity `json:"q"`
+		}
+		err := json.Unmarshal([]byte(`{"q":`+tt.in+`}`), &got)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got.Q != tt.want) {
+			t.Errorf("unmarshal %s = %s, %v; want %s, error %v", tt.in, got.Q, err, tt.want, tt.wantErr)
+		}
+	}
+
+	q := Units(7)
+	if err := json.Unmarshal([]byte(`null`), &q); err != nil || q != Units(7) {
+		t.Errorf("unmarshal null = %s, %v; want 7 left as it was", q, err)
+	}
+	if b, _ := json.Marshal(Thousandths(1250)); string(b) != "1.25" {
+		t.Errorf("marshal 1.250 = %s, want 1.25", b)
+	}
+}
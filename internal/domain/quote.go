@@ -0,0 +1,35 @@
+package domain
+
+// Quote warning codes.
+const (
+	QuoteWarningInsufficientStock = "insufficient_stock"
+	QuoteWarningLowStock          = "low_stock"
+	QuoteWarningInactive          = "inactive"
+)
+
+// QuoteWarning flags a product in a quote that would fail or deserves attention at checkout.
+type QuoteWarning struct {
+	Code      string `json:"code"`
+	ProductID int    `json:"product_id"`
+	Message   string `json:"message"`
+}
+
+// CheckoutQuote is the response for POST /api/checkout/quote: the amounts a checkout of the same
+// request would produce right now, without saving anything. Payments and Change are only filled
+// when the request has tenders.
+type CheckoutQuote struct {
+	GrossAmount         int                 `json:"gross_amount"`
+	DiscountAmount      int                 `json:"discount_amount"`
+	CartDiscountAmount  int                 `json:"cart_discount_amount"`
+	NetAmount           int                 `json:"net_amount"`
+	ServiceChargeAmount int                 `json:"service_charge_amount"`
+	TaxAmount           int                 `json:"tax_amount"`
+	TotalAmount         int                 `json:"total_amount"`
+	TaxRate             float64             `json:"tax_rate"`
+	TaxInclusive        bool                `json:"tax_inclusive"`
+	ServiceChargeRate   float64             `json:"service_charge_rate"`
+	Change              int                 `json:"change"`
+	Details             []TransactionDetail `json:"details"`
+	Payments            []Payment           `json:"payments,omitempty"`
+	Warnings            []QuoteWarning      `json:"warnings"`
+}
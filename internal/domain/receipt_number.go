@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Receipt number reset periods.
+const (
+	ReceiptResetDaily   = "daily"
+	ReceiptResetMonthly = "monthly"
+	ReceiptResetYearly  = "yearly"
+	ReceiptResetNever   = "never"
+)
+
+// ReceiptNumbering describes how receipt numbers are built. Pattern may contain {OUTLET}, {YYYY},
+// {YY}, {MM}, {DD} and {SEQ} or {SEQ:n} for the sequence zero-padded to n digits, e.g.
+// "INV/{YYYY}/{MM}/{DD}/{SEQ:4}". The sequence starts again at 1 every Reset period, separately
+// for each outlet.
+type ReceiptNumbering struct {
+	Pattern string
+	Reset   string
+	Outlet  string
+}
+
+var seqToken = regexp.MustCompile(`\{SEQ(?::(\d+))?\}`)
+
+// Validate checks that the pattern contains the sequence, every date part the reset period needs
+// and the outlet code if one is set, so that numbers cannot repeat within or across outlets.
+func (n ReceiptNumbering) Validate() error {
+	if !seqToken.MatchString(n.Pattern) {
+		return errors.New("receipt number pattern must contain {SEQ}")
+	}
+	hasYear := strings.Contains(n.Pattern, "{YYYY}") || strings.Contains(n.Pattern, "{YY}")
+	hasMonth := strings.Contains(n.Pattern, "{MM}")
+	hasDay := strings.Contains(n.Pattern, "{DD}")
+	switch n.Reset {
+	case ReceiptResetNever:
+	case ReceiptResetYearly:
+		if !hasYear {
+			return errors.New("receipt numbers reset yearly need {YYYY} or {YY} in the pattern")
+		}
+	case ReceiptResetMonthly:
+		if !hasYear || !hasMonth {
+			return errors.New("receipt numbers reset monthly need the year and {MM} in the pattern")
+		}
+	case ReceiptResetDaily:
+		if !hasYear || !hasMonth || !hasDay {
+			return errors.New("receipt numbers reset daily need the year, {MM} and {DD} in the pattern")
+		}
+	default:
+		return fmt.Errorf("receipt number reset must be daily, monthly, yearly or never, got %q", n.Reset)
+	}
+	hasOutlet := strings.Contains(n.Pattern, "{OUTLET}")
+	if n.Outlet == "" && hasOutlet {
+		return errors.New("receipt number pattern uses {OUTLET} but no outlet code is set")
+	}
+	if n.Outlet != "" && !hasOutlet {
+		return errors.New("receipt number pattern must contain {OUTLET} when an outlet code is set")
+	}
+	return nil
+}
+
+// Scope returns the name of the counter that a transaction made at t draws its sequence from.
+func (n ReceiptNumbering) Scope(t time.Time) string {
+	period := "all"
+	switch n.Reset {
+	case ReceiptResetDaily:
+		period = t.Format("2006-01-02")
+	case ReceiptResetMonthly:
+		period = t.Format("2006-01")
+	case ReceiptResetYearly:
+		period = t.Format("2006")
+	}
+	return n.Outlet + "|" + period
+}
+
+// Format returns receipt number seq for a transaction made at t.
+func (n ReceiptNumbering) Format(t time.Time, seq int) string {
+	s := strings.NewReplacer(
+		"{OUTLET}", n.Outlet,
+		"{YYYY}", t.Format("2006"),
+		"{YY}", t.Format("06"),
+		"{MM}", t.Format("01"),
+		"{DD}", t.Format("02"),
+	).Replace(n.Pattern)
+	return seqToken.ReplaceAllStringFunc(s, func(tok string) string {
+		width := 0
+		if m := seqToken.FindStringSubmatch(tok); m[1] != "" {
+			width, _ = strconv.Atoi(m[1])
+		}
+		return fmt.Sprintf("%0*d", width, seq)
+	})
+}
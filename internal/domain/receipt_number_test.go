@@ -0,0 +1,21 @@
+package domain
+
+import "testing"
+
+func TestReceiptNumberingValidateOutlet(t *testing.T) {
+	tests := []struct {
+		pattern, outlet string
+		ok              bool
+	}{
+		{"INV/{YYYY}/{MM}/{DD}/{SEQ:4}", "", true},
+		{"INV/{OUTLET}/{YYYY}/{MM}/{DD}/{SEQ:4}", "JKT", true},
+		{"INV/{YYYY}/{MM}/{DD}/{SEQ:4}", "JKT", false},
+		{"INV/{OUTLET}/{YYYY}/{MM}/{DD}/{SEQ:4}", "", false},
+	}
+	for _, tt := range tests {
+		err := ReceiptNumbering{Pattern: tt.pattern, Reset: ReceiptResetDaily, Outlet: tt.outlet}.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("pattern %q, outlet %q: err = %v, want ok %v", tt.pattern, tt.outlet, err, tt.ok)
+		}
+	}
+}
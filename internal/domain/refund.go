@@ -0,0 +1,51 @@
+package domain
+
+import "time"
+
+// Refund types.
+const (
+	RefundTypeVoid   = "void"
+	RefundTypeRefund = "refund"
+)
+
+// Refund records money and stock returned for a transaction, either by voiding it or by
+// refunding individual lines.
+type Refund struct {
+	ID            int          `json:"id"`
+	TransactionID int          `json:"transaction_id"`
+	Type          string       `json:"type"`
+	Amount        int          `json:"amount"`
+	Reason        string       `json:"reason"`
+	PerformedBy   string       `json:"performed_by"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Items         []RefundItem `json:"items"`
+}
+
+// RefundItem is the quantity of one transaction detail line returned by a refund.
+type RefundItem struct {
+	ID                  int      `json:"id"`
+	RefundID            int      `json:"refund_id"`
+	TransactionDetailID int      `json:"transaction_detail_id"`
+	ProductID           int      `json:"product_id"`
+	Quantity            Quantity `json:"quantity"`
+	Amount              int      `json:"amount"`
+}
+
+// VoidRequest is the body for POST /api/transactions/{id}/void.
+type VoidRequest struct {
+	Reason      string `json:"reason"`
+	PerformedBy string `json:"performed_by"`
+}
+
+// RefundRequest is the body for POST /api/transactions/{id}/refund.
+type RefundRequest struct {
+	Reason      string       `json:"reason"`
+	PerformedBy string       `json:"performed_by"`
+	Items       []RefundLine `json:"items"`
+}
+
+// RefundLine asks to refund Quantity units of a transaction detail line.
+type RefundLine struct {
+	DetailID int      `json:"detail_id"`
+	Quantity Quantity `json:"quantity"`
+}
@@ -1,14 +1,43 @@
 package domain
 
 // SummaryHariIni is the response for GET /api/report/hari-ini.
+// TotalRevenue is net sales without tax and service charge, after TotalRefund (the full amount
+// refunded or voided today). TotalPajak and TotalServiceCharge are reported separately, also net
+// of refunds. TotalDiskon is the discount given on today's transactions, less the share of it on
+// what was refunded today.
+// PaketTerjual lists bundles sold and PemakaianKomponen the component stock they used, both net of
+// refunds and in base units.
 type SummaryHariIni struct {
-	TotalRevenue   int            `json:"total_revenue"`
-	TotalTransaksi int            `json:"total_transaksi"`
-	ProdukTerlaris ProdukTerlaris `json:"produk_terlaris"`
+	TotalRevenue       int            `json:"total_revenue"`
+	TotalRefund        int            `json:"total_refund"`
+	TotalDiskon        int            `json:"total_diskon"`
+	TotalPajak         int            `json:"total_pajak"`
+	TotalServiceCharge int            `json:"total_service_charge"`
+	TotalTransaksi     int            `json:"total_transaksi"`
+	ProdukTerlaris     ProdukTerlaris `json:"produk_terlaris"`
+	PerMetode          []PaymentTotal `json:"per_metode_pembayaran"`
+	PaketTerjual       []ProductQty   `json:"paket_terjual"`
+	PemakaianKomponen  []ProductQty   `json:"pemakaian_komponen"`
+}
+
+// PaymentTotal is the amount settled with one payment method, net of refunds. Refunds go back to
+// the methods their transaction was paid with, in proportion. TotalTransaksi counts the
+// transactions paid with the method that were not voided.
+type PaymentTotal struct {
+	Method         string `json:"method"`
+	Total          int    `json:"total"`
+	TotalTransaksi int    `json:"total_transaksi"`
+}
+
+// ProductQty is a quantity of one product over the day.
+type ProductQty struct {
+	ProductID int      `json:"product_id"`
+	Nama      string   `json:"nama"`
+	Qty       Quantity `json:"qty"`
 }
 
 // ProdukTerlaris holds the best-selling product for the day.
 type ProdukTerlaris struct {
-	Nama       string `json:"nama"`
-	QtyTerjual int    `json:"qty_terjual"`
+	Nama       string   `json:"nama"`
+	QtyTerjual Quantity `json:"qty_terjual"`
 }
@@ -2,28 +2,136 @@ package domain
 
 import "time"
 
+// Transaction statuses.
+const (
+	TransactionStatusCompleted         = "completed"
+	TransactionStatusPartiallyRefunded = "partially_refunded"
+	TransactionStatusRefunded          = "refunded"
+	TransactionStatusVoided            = "voided"
+)
+
 // Transaction is the domain entity for a transaction.
+//
+// GrossAmount - DiscountAmount is what the lines sell for; CartDiscountAmount is the cart-level part
+// of DiscountAmount. NetAmount is that amount without tax (equal to it unless prices include tax),
+// and TotalAmount = NetAmount + ServiceChargeAmount + TaxAmount is what the customer pays.
+// ReceiptNumber is the human-readable number printed on the receipt, e.g. INV/2026/10/18/0001.
+// ReceiptToken is the random, unguessable key of the public receipt link.
+// CartID is set when the transaction was created by checking out a parked cart.
+// IdempotencyKey, set only on a draft, is the reserved key the new transaction is stored under.
 type Transaction struct {
-	ID          int                 `json:"id"`
-	TotalAmount int                 `json:"total_amount"`
-	CreatedAt   time.Time           `json:"created_at"`
-	Details     []TransactionDetail `json:"details"`
+	ID                  int                 `json:"id"`
+	ReceiptNumber       string              `json:"receipt_number,omitempty"`
+	ReceiptToken        string              `json:"receipt_token,omitempty"`
+	GrossAmount         int                 `json:"gross_amount"`
+	DiscountAmount      int                 `json:"discount_amount"`
+	CartDiscountAmount  int                 `json:"cart_discount_amount"`
+	NetAmount           int                 `json:"net_amount"`
+	ServiceChargeAmount int                 `json:"service_charge_amount"`
+	TaxAmount           int                 `json:"tax_amount"`
+	TotalAmount         int                 `json:"total_amount"`
+	TaxRate             float64             `json:"tax_rate"`
+	TaxInclusive        bool                `json:"tax_inclusive"`
+	ServiceChargeRate   float64             `json:"service_charge_rate"`
+	Status              string              `json:"status"`
+	CartID              int                 `json:"cart_id,omitempty"`
+	IdempotencyKey      string              `json:"-"`
+	CreatedAt           time.Time           `json:"created_at"`
+	Change              int                 `json:"change"`
+	Details             []TransactionDetail `json:"details,omitempty"`
+	Payments            []Payment           `json:"payments,omitempty"`
+	Refunds             []Refund            `json:"refunds,omitempty"`
 }
 
+// TransactionDetail is one line of a transaction. ProductName, UnitPrice and UnitCost are
+// snapshots taken at the time of sale. GrossAmount - DiscountAmount = Subtotal, where
+// DiscountAmount includes the line's share of the cart discount.
+//
+// Quantity, UnitPrice and UnitCost are in Unit, which holds UnitFactor of the product's base unit.
+// Components is set for bundles and records the stock each component gave. UnitPrice includes the
+// price of the Modifiers picked on the line.
 type TransactionDetail struct {
-	ID            int    `json:"id"`
-	TransactionID int    `json:"transaction_id"`
-	ProductID     int    `json:"product_id"`
-	ProductName   string `json:"product_name,omitempty"`
-	Quantity      int    `json:"quantity"`
-	Subtotal      int    `json:"subtotal"`
+	ID               int      `json:"id"`
+	TransactionID    int      `json:"transaction_id"`
+	ProductID        int      `json:"product_id"`
+	ProductName      string   `json:"product_name,omitempty"`
+	UnitPrice        int      `json:"unit_price"`
+	UnitCost         int      `json:"unit_cost"`
+	Quantity         Quantity `json:"quantity"`
+	Unit             string   `json:"unit,omitempty"`
+	UnitFactor       Quantity `json:"unit_factor,omitempty"`
+	GrossAmount      int      `json:"gross_amount"`
+	DiscountAmount   int      `json:"discount_amount"`
+	Subtotal         int      `json:"subtotal"`
+	RefundedQuantity Quantity `json:"refunded_quantity,omitempty"`
+
+	Components []BundleComponent `json:"components,omitempty"`
+	Modifiers  []DetailModifier  `json:"modifiers,omitempty"`
+}
+
+// BaseQuantity returns the line's quantity in the product's base unit, which is what stock counts.
+func (d TransactionDetail) BaseQuantity() Quantity {
+	if d.UnitFactor == 0 {
+		return d.Quantity
+	}
+	return d.Quantity.Mul(d.UnitFactor)
 }
 
+// CheckoutItem is one line of a checkout or cart. The product is given either by ProductID or by
+// a barcode, which may be an in-store scale label; Quantity then defaults to 1 and counts labels.
+// Amount is set, not read from JSON, for price labels: it is the line's gross amount and overrides
+// the product price times Quantity. Unit selects one of the product's units; empty means its base unit.
+// Modifiers are the IDs of the modifier options picked for the line.
 type CheckoutItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID int       `json:"product_id"`
+	Barcode   string    `json:"barcode,omitempty"`
+	Quantity  Quantity  `json:"quantity"`
+	Unit      string    `json:"unit,omitempty"`
+	Modifiers []int     `json:"modifiers,omitempty"`
+	Discount  *Discount `json:"discount,omitempty"`
+	Amount    int       `json:"-"`
 }
 
+// CheckoutRequest is the body for POST /api/checkout. Discount applies to the whole cart after line
+// discounts. Payment is shorthand for a single tender; use Payments to split the bill across several tenders.
 type CheckoutRequest struct {
-	Items []CheckoutItem `json:"items"`
+	Items    []CheckoutItem `json:"items"`
+	Discount *Discount      `json:"discount,omitempty"`
+	Payment  *PaymentInput  `json:"payment,omitempty"`
+	Payments []PaymentInput `json:"payments,omitempty"`
+}
+
+// Tenders returns the tenders of the request from either Payment or Payments.
+func (r CheckoutRequest) Tenders() []PaymentInput {
+	if r.Payment != nil {
+		return append([]PaymentInput{*r.Payment}, r.Payments...)
+	}
+	return r.Payments
+}
+
+// StockShortage describes a checkout line that cannot be fulfilled from current stock.
+type StockShortage struct {
+	ProductID int      `json:"product_id"`
+	Requested Quantity `json:"requested"`
+	Available Quantity `json:"available"`
+}
+
+// TransactionFilter narrows GET /api/transactions. Zero values mean "no filter";
+// From is inclusive and To is exclusive.
+type TransactionFilter struct {
+	Page      int
+	Limit     int
+	From      *time.Time
+	To        *time.Time
+	MinTotal  *int
+	MaxTotal  *int
+	ProductID int
+}
+
+// TransactionList is one page of transactions.
+type TransactionList struct {
+	Data  []Transaction `json:"data"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+	Total int           `json:"total"`
 }
@@ -0,0 +1,45 @@
+package domain
+
+import "strings"
+
+// HasVariants reports whether p is sold through variants rather than by itself.
+func (p Product) HasVariants() bool {
+	return len(p.VariantAttributes) > 0
+}
+
+// VariantName builds the name of a variant of parent from its attribute values in the parent's
+// attribute order, e.g. "Kaos - M - Hitam" for attributes ukuran and warna.
+func VariantName(parent Product, attrs map[string]string) string {
+	parts := []string{parent.Nama}
+	for _, a := range parent.VariantAttributes {
+		parts = append(parts, attrs[a])
+	}
+	return strings.Join(parts, " - ")
+}
+
+// GroupVariants nests variants under their parent when the parent is in products, keeping the
+// order of products otherwise. Variants whose parent was filtered out stay at the top level, so a
+// search for "Hitam" still finds "Kaos - M - Hitam". Parents may come before or after their variants.
+func GroupVariants(products []Product) []Product {
+	parents := make(map[int]bool)
+	for _, p := range products {
+		if p.HasVariants() {
+			parents[p.ID] = true
+		}
+	}
+	variants := make(map[int][]Product)
+	out := make([]Product, 0, len(products))
+	for _, p := range products {
+		if p.ParentID != nil && parents[*p.ParentID] {
+			variants[*p.ParentID] = append(variants[*p.ParentID], p)
+			continue
+		}
+		out = append(out, p)
+	}
+	for i := range out {
+		if vs, ok := variants[out[i].ID]; ok && out[i].HasVariants() {
+			out[i].Variants = append(out[i].Variants, vs...)
+		}
+	}
+	return out
+}
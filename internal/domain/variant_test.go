@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGroupVariantsParentAfterVariant(t *testing.T) {
+	kaos, topi := 9, 20
+	products := []Product{
+		{ID: 3, Nama: "Kaos - M - Hitam", ParentID: &kaos},
+		{ID: 5, Nama: "Topi - L", ParentID: &topi},
+		{ID: 7, Nama: "Gelas"},
+		{ID: 9, Nama: "Kaos", VariantAttributes: []string{"ukuran", "warna"}},
+		{ID: 11, Nama: "Kaos - L - Hitam", ParentID: &kaos},
+	}
+	got := GroupVariants(products)
+
+	var ids []int
+	for _, p := range got {
+		ids = append(ids, p.ID)
+	}
+	if want := []int{5, 7, 9}; !slices.Equal(ids, want) {
+		t.Fatalf("top level = %v, want %v", ids, want)
+	}
+	if vs := got[2].Variants; len(vs) != 2 || vs[0].ID != 3 || vs[1].ID != 11 {
+		t.Errorf("variants of Kaos = %+v, want 3 and 11", vs)
+	}
+}
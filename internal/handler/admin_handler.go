@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"kasir-api/internal/usecase"
+)
+
+const adminTokenHeader = "X-Admin-Token"
+
+// AdminHandler handles maintenance endpoints that require the admin token.
+type AdminHandler struct {
+	products   *usecase.ProductUsecase
+	categories *usecase.CategoryUsecase
+	token      string
+}
+
+// NewAdminHandler creates an admin handler. Requests must send token in the X-Admin-Token header;
+// an empty token disables the admin endpoints.
+func NewAdminHandler(products *usecase.ProductUsecase, categories *usecase.CategoryUsecase, token string) *AdminHandler {
+	return &AdminHandler{products: products, categories: categories, token: token}
+}
+
+// authorized reports whether r carries the admin token, writing an error response if not.
+func (h *AdminHandler) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if h.token == "" {
+		writeError(w, http.StatusForbidden, "Admin endpoints are disabled; set ADMIN_TOKEN to enable them")
+		return false
+	}
+	got := r.Header.Get(adminTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, "Invalid admin token")
+		return false
+	}
+	return true
+}
+
+// Purge handles POST /api/admin/purge: permanently removes deleted products that no transaction
+// refers to, then deleted categories left without products.
+func (h *AdminHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+	products, err := h.products.Purge()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	categories, err := h.categories.Purge()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":            "success",
+		"products_purged":   products,
+		"categories_purged": categories,
+	})
+}
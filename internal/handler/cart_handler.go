@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+	"kasir-api/internal/usecase"
+)
+
+type CartHandler struct {
+	uc      *usecase.CartUsecase
+	useLock bool
+}
+
+// NewCartHandler creates a cart handler. useLock selects the checkout concurrency strategy.
+func NewCartHandler(uc *usecase.CartUsecase, useLock bool) *CartHandler {
+	return &CartHandler{uc: uc, useLock: useLock}
+}
+
+// Create handles POST /api/carts.
+// Body: {"items": [{"product_id": 1, "quantity": 2}, ...], "discount": {...}, "reserve_stock": true}.
+func (h *CartHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateCartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	for _, item := range req.Items {
+		if msg := validateCheckoutItem(item); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+	}
+	c, err := h.uc.Create(req)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// GetByID handles GET /api/carts/:id
+func (h *CartHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromPath(r.URL.Path, "/api/carts/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid cart ID")
+		return
+	}
+	c, err := h.uc.GetByID(id)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Cancel handles DELETE /api/carts/:id, releasing any stock the cart reserved.
+func (h *CartHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromPath(r.URL.Path, "/api/carts/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid cart ID")
+		return
+	}
+	if err := h.uc.Cancel(id); err != nil {
+		writeCartError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "ok",
+		"message": "Cart cancelled",
+	})
+}
+
+// AddItem handles POST /api/carts/:id/items. Body: {"product_id": 1, "quantity": 2, "discount": {...}};
+// "barcode" may be sent instead of "product_id".
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromSubpath(r.URL.Path, "/api/carts/", "/items")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid cart ID")
+		return
+	}
+	var item domain.CheckoutItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := validateCheckoutItem(item); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	c, err := h.uc.AddItem(id, item)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// UpdateItem handles PUT /api/carts/:id/items/:itemId. Body: {"quantity": 3, "discount": {...}}.
+func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	id, itemID, ok := parseCartItemPath(r.URL.Path)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid cart or item ID")
+		return
+	}
+	var item domain.CheckoutItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if item.Quantity <= 0 {
+		writeError(w, http.StatusBadRequest, "quantity must be greater than 0")
+		return
+	}
+	c, err := h.uc.UpdateItem(id, itemID, item)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// RemoveItem handles DELETE /api/carts/:id/items/:itemId
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	id, itemID, ok := parseCartItemPath(r.URL.Path)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid cart or item ID")
+		return
+	}
+	c, err := h.uc.RemoveItem(id, itemID)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Checkout handles POST /api/carts/:id/checkout. Body: {"payments": [{"method": "cash", "amount": 100000}, ...]}.
+func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromSubpath(r.URL.Path, "/api/carts/", "/checkout")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid cart ID")
+		return
+	}
+	var req domain.CartCheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Payment != nil && len(req.Payments) > 0 {
+		writeError(w, http.StatusBadRequest, "use either payment or payments, not both")
+		return
+	}
+	tenders := domain.CheckoutRequest{Payment: req.Payment, Payments: req.Payments}.Tenders()
+	for _, t := range tenders {
+		if !domain.ValidPaymentMethod(t.Method) {
+			writeError(w, http.StatusBadRequest, "payment method must be one of cash, debit, qris, transfer")
+			return
+		}
+	}
+	tx, err := h.uc.Checkout(id, req, h.useLock)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, tx)
+}
+
+// parseCartItemPath extracts the cart and item IDs from "/api/carts/:id/items/:itemId".
+func parseCartItemPath(path string) (cartID, itemID int, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/carts/"), "/")
+	if len(parts) != 3 || parts[1] != "items" {
+		return 0, 0, false
+	}
+	cartID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	itemID, err = strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, false
+	}
+	return cartID, itemID, true
+}
+
+// writeCartError maps cart errors to HTTP responses; checkout errors are mapped like POST /api/checkout.
+func writeCartError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrCartNotOpen):
+		writeError(w, http.StatusConflict, "Cart is no longer open")
+	case errors.Is(err, usecase.ErrCartEmpty):
+		writeError(w, http.StatusUnprocessableEntity, "Cart has no items")
+	case errors.Is(err, usecase.ErrPriceBarcodeInCart):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		writeCheckoutError(w, err)
+	}
+}
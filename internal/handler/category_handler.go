@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 
 	"kasir-api/internal/domain"
 	"kasir-api/internal/repository"
@@ -39,8 +40,24 @@ func (h *CategoryHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, cat)
 }
 
-// GetAll handles GET /api/categories
+// GetAll handles GET /api/categories. With ?tree=true, categories are nested under their parents.
 func (h *CategoryHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	if v := r.URL.Query().Get("tree"); v != "" {
+		tree, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid tree")
+			return
+		}
+		if tree {
+			nodes, err := h.uc.Tree()
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, nodes)
+			return
+		}
+	}
 	cats, err := h.uc.GetAll()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -49,7 +66,7 @@ func (h *CategoryHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, cats)
 }
 
-// Create handles POST /api/categories
+// Create handles POST /api/categories. An optional "parent_id" places it under another category.
 func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var c domain.Category
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
@@ -58,26 +75,48 @@ func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 	created, err := h.uc.Create(c)
 	if err != nil {
+		if errors.Is(err, usecase.ErrCategoryNotFound) {
+			writeError(w, http.StatusBadRequest, "Parent category not found")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	writeJSON(w, http.StatusCreated, created)
 }
 
-// Update handles PUT /api/categories/:id
+// Update handles PUT /api/categories/:id. If "parent_id" is omitted the category keeps its parent;
+// "parent_id": null makes it a top-level category.
 func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, ok := parseIDFromPath(r.URL.Path, "/api/categories/")
 	if !ok {
 		writeError(w, http.StatusBadRequest, "Invalid category ID")
 		return
 	}
-	var c domain.Category
+	current, err := h.uc.GetByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Category not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c := domain.Category{ParentID: current.ParentID}
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 	updated, err := h.uc.Update(id, c)
 	if err != nil {
+		if errors.Is(err, usecase.ErrCategoryNotFound) {
+			writeError(w, http.StatusBadRequest, "Parent category not found")
+			return
+		}
+		if errors.Is(err, usecase.ErrCategoryCycle) {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
 		if errors.Is(err, repository.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "Category not found")
 			return
@@ -88,15 +127,43 @@ func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, updated)
 }
 
-// Delete handles DELETE /api/categories/:id
+// Delete handles DELETE /api/categories/:id. A category that still has products is only deleted
+// with ?reassign_to={id}, which moves its products to that category first.
 func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, ok := parseIDFromPath(r.URL.Path, "/api/categories/")
 	if !ok {
 		writeError(w, http.StatusBadRequest, "Invalid category ID")
 		return
 	}
-	err := h.uc.Delete(id)
+	reassignTo, ok := parseIntParam(r.URL.Query().Get("reassign_to"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid reassign_to")
+		return
+	}
+	if reassignTo == id {
+		writeError(w, http.StatusBadRequest, "reassign_to must be a different category")
+		return
+	}
+	err := h.uc.Delete(id, reassignTo)
 	if err != nil {
+		var inUse *usecase.ErrCategoryInUse
+		if errors.As(err, &inUse) {
+			msg := "Category still has products; pass reassign_to to move them"
+			if inUse.Subcategories > 0 {
+				msg = "Category still has subcategories; move or delete them first"
+			}
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"status":        "error",
+				"message":       msg,
+				"products":      inUse.Products,
+				"subcategories": inUse.Subcategories,
+			})
+			return
+		}
+		if errors.Is(err, usecase.ErrCategoryNotFound) {
+			writeError(w, http.StatusBadRequest, "reassign_to category not found")
+			return
+		}
 		if errors.Is(err, repository.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "Category not found")
 			return
@@ -109,3 +176,24 @@ func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		"message": "Category deleted successfully",
 	})
 }
+
+// Restore handles POST /api/categories/:id/restore, undoing a delete.
+func (h *CategoryHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromSubpath(r.URL.Path, "/api/categories/", "/restore")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+	if err := h.uc.Restore(id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Deleted category not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Category restored successfully",
+	})
+}
@@ -18,6 +18,27 @@ func parseIDFromPath(path, prefix string) (int, bool) {
 	return id, true
 }
 
+// parseIDFromSubpath extracts the integer ID from paths like "/api/transactions/12/void",
+// given the prefix "/api/transactions/" and suffix "/void". Returns (0, false) if parsing fails.
+func parseIDFromSubpath(path, prefix, suffix string) (int, bool) {
+	if !strings.HasSuffix(path, suffix) {
+		return 0, false
+	}
+	return parseIDFromPath(strings.TrimSuffix(path, suffix), prefix)
+}
+
+// parseIntParam parses an optional non-negative integer query value. Empty means 0.
+func parseIntParam(v string) (int, bool) {
+	if v == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
 // writeJSON sets Content-Type and encodes v as JSON with status code.
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
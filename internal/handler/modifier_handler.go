@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+	"kasir-api/internal/usecase"
+)
+
+// ModifierHandler handles HTTP for modifier groups.
+type ModifierHandler struct {
+	uc *usecase.ModifierUsecase
+}
+
+// NewModifierHandler creates a new modifier group HTTP handler.
+func NewModifierHandler(uc *usecase.ModifierUsecase) *ModifierHandler {
+	return &ModifierHandler{uc: uc}
+}
+
+// GetAll handles GET /api/modifier-groups
+func (h *ModifierHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.uc.GetAll()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// GetByID handles GET /api/modifier-groups/:id
+func (h *ModifierHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromPath(r.URL.Path, "/api/modifier-groups/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid modifier group ID")
+		return
+	}
+	g, err := h.uc.GetByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Modifier group not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+// Create handles POST /api/modifier-groups. The group applies to the products in "product_ids" and
+// the products of the categories in "category_ids".
+func (h *ModifierHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var g domain.ModifierGroup
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	created, err := h.uc.Create(g)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidModifierGroup) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// Update handles PUT /api/modifier-groups/:id. Options sent with their "id" are kept and updated,
+// options without one are added, and options left out are removed.
+func (h *ModifierHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromPath(r.URL.Path, "/api/modifier-groups/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid modifier group ID")
+		return
+	}
+	var g domain.ModifierGroup
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	updated, err := h.uc.Update(id, g)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidModifierGroup) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Modifier group not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Delete handles DELETE /api/modifier-groups/:id. Past transactions keep the modifiers they were sold with.
+func (h *ModifierHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromPath(r.URL.Path, "/api/modifier-groups/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid modifier group ID")
+		return
+	}
+	if err := h.uc.Delete(id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Modifier group not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Modifier group deleted successfully",
+	})
+}
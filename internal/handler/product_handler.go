@@ -3,7 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"kasir-api/internal/domain"
 	"kasir-api/internal/repository"
@@ -39,49 +42,100 @@ func (h *ProductHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, prod)
 }
 
-// GetAll handles GET /api/products. Optional query: name=Nike to filter by product name.
+// Lookup handles GET /api/products/lookup?barcode=8992761111113, for barcode scanners.
+func (h *ProductHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	barcode := strings.TrimSpace(r.URL.Query().Get("barcode"))
+	if barcode == "" {
+		writeError(w, http.StatusBadRequest, "barcode required")
+		return
+	}
+	prod, err := h.uc.GetByBarcode(barcode)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Product not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, prod)
+}
+
+// writeProductWriteError maps errors from creating or updating a product to HTTP responses.
+func writeProductWriteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrCategoryNotFound):
+		writeError(w, http.StatusBadRequest, "Category not found")
+	case errors.Is(err, usecase.ErrInvalidBarcode), errors.Is(err, usecase.ErrInvalidUnit),
+		errors.Is(err, usecase.ErrInvalidVariant), errors.Is(err, usecase.ErrInvalidBundle):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrDuplicate):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "Product not found")
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// GetAll handles GET /api/products. Optional query: name=Nike to filter by product name,
+// category_id=2 to list products of that category and its subcategories, and include_inactive=true
+// to list inactive products too. Variants are listed under their parent product.
 func (h *ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	name := r.URL.Query().Get("name")
-	prods, err := h.uc.GetAll(name)
+	q := r.URL.Query()
+	f := domain.ProductFilter{Name: q.Get("name")}
+	var ok bool
+	if f.CategoryID, ok = parseIntParam(q.Get("category_id")); !ok {
+		writeError(w, http.StatusBadRequest, "Invalid category_id")
+		return
+	}
+	if v := q.Get("include_inactive"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid include_inactive")
+			return
+		}
+		f.IncludeInactive = include
+	}
+	prods, err := h.uc.GetAll(f)
 	if err != nil {
+		if errors.Is(err, usecase.ErrCategoryNotFound) {
+			writeError(w, http.StatusNotFound, "Category not found")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	writeJSON(w, http.StatusOK, prods)
 }
 
-// Create handles POST /api/products
+// Create handles POST /api/products. Products are active unless the body sets "active": false.
 func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var p domain.Product
+	p := domain.Product{Active: true}
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 	created, err := h.uc.Create(p)
 	if err != nil {
-		if errors.Is(err, usecase.ErrCategoryNotFound) {
-			writeError(w, http.StatusBadRequest, "Category not found")
-			return
-		}
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeProductWriteError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusCreated, created)
 }
 
-// Update handles PUT /api/products/:id
+// Update handles PUT /api/products/:id. Fields left out of the body keep their current value, so
+// {"active": false} only takes a product off sale. Stock is only written when the body has "stok",
+// and never for bundles. "barcodes" and "units" replace the whole list,
+// "parent_id": null makes a variant a product of its own and "components": [] makes a bundle a
+// plain product.
 func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, ok := parseIDFromPath(r.URL.Path, "/api/products/")
 	if !ok {
 		writeError(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
-	var p domain.Product
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-	updated, err := h.uc.Update(id, p)
+	current, err := h.uc.GetByID(id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "Product not found")
@@ -90,10 +144,44 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	p := domain.Product{
+		Nama:       current.Nama,
+		Harga:      current.Harga,
+		HargaPokok: current.HargaPokok,
+		Category:   current.Category,
+		Active:     current.Active,
+		SKU:        current.SKU,
+		Barcodes:   current.Barcodes,
+		Unit:       current.Unit,
+		Units:      current.Units,
+
+		ParentID:          current.ParentID,
+		VariantAttributes: current.VariantAttributes,
+		Attributes:        current.Attributes,
+		Components:        current.Components,
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	// stok is read apart so that leaving it out keeps the stock the database has at update time.
+	var sent struct {
+		Stok *domain.Quantity `json:"stok"`
+	}
+	if json.Unmarshal(body, &p) != nil || json.Unmarshal(body, &sent) != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	updated, err := h.uc.Update(id, p, sent.Stok)
+	if err != nil {
+		writeProductWriteError(w, err)
+		return
+	}
 	writeJSON(w, http.StatusOK, updated)
 }
 
-// Delete handles DELETE /api/products/:id
+// Delete handles DELETE /api/products/:id. A product still used by a bundle is refused with 409.
 func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, ok := parseIDFromPath(r.URL.Path, "/api/products/")
 	if !ok {
@@ -106,6 +194,10 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "Product not found")
 			return
 		}
+		if errors.Is(err, usecase.ErrProductInBundle) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -114,3 +206,24 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		"message": "Product deleted successfully",
 	})
 }
+
+// Restore handles POST /api/products/:id/restore, undoing a delete.
+func (h *ProductHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromSubpath(r.URL.Path, "/api/products/", "/restore")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	if err := h.uc.Restore(id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Deleted product not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Product restored successfully",
+	})
+}
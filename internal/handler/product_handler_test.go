@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+	"kasir-api/internal/usecase"
+)
+
+// recordingProducts remembers the last product and stock written by Update.
+type recordingProducts struct {
+	*repository.ProductMemoryRepo
+	updated domain.Product
+	stock   *domain.Quantity
+}
+
+func (r *recordingProducts) Update(id int, p domain.Product, stock *domain.Quantity) (domain.Product, error) {
+	r.updated, r.stock = p, stock
+	return r.ProductMemoryRepo.Update(id, p, stock)
+}
+
+func TestProductUpdatePartialBody(t *testing.T) {
+	products := &recordingProducts{ProductMemoryRepo: repository.NewProductMemoryRepo([]domain.Product{{
+		ID:         1,
+		Nama:       "Kopi Susu",
+		SKU:        "KS-01",
+		Harga:      18000,
+		HargaPokok: 9000,
+		Stok:       domain.Units(25),
+		Unit:       "cup",
+		Active:     true,
+		Category:   domain.Category{ID: 2, Nama: "Minuman"},
+	}})}
+	categories := repository.NewCategoryMemoryRepo([]domain.Category{{ID: 2, Nama: "Minuman"}}, products.ProductMemoryRepo)
+	h := NewProductHandler(usecase.NewProductUsecase(products, categories, nil))
+
+	w := httptest.NewRecorder()
+	h.Update(w, httptest.NewRequest(http.MethodPut, "/api/products/1", strings.NewReader(`{"active": false}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	got := products.updated
+	if got.Active {
+		t.Error("product is still active")
+	}
+	if got.Nama != "Kopi Susu" || got.SKU != "KS-01" || got.Harga != 18000 || got.Unit != "cup" || got.Category.ID != 2 {
+		t.Errorf("omitted fields were not kept: %+v", got)
+	}
+	if products.stock != nil {
+		t.Errorf("stok was written as %s without being sent", products.stock)
+	}
+	if got.HargaPokok != 9000 {
+		t.Errorf("harga_pokok = %d, want the stored 9000", got.HargaPokok)
+	}
+
+	w = httptest.NewRecorder()
+	h.Update(w, httptest.NewRequest(http.MethodPut, "/api/products/1", strings.NewReader(`{"stok": 30}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if products.stock == nil || *products.stock != domain.Units(30) {
+		t.Errorf("stok sent as 30, written as %v", products.stock)
+	}
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/receipt"
+	"kasir-api/internal/repository"
+	"kasir-api/internal/usecase"
+)
+
+// Receipt formats accepted by GET /api/transactions/{id}/receipt and GET /api/receipts/{token}.
+const (
+	receiptFormatText   = "text"
+	receiptFormatESCPOS = "escpos"
+	receiptFormatHTML   = "html"
+	receiptFormatPDF    = "pdf"
+)
+
+// receiptMediaTypes maps Accept media types to receipt formats.
+var receiptMediaTypes = map[string]string{
+	"text/plain":               receiptFormatText,
+	"application/octet-stream": receiptFormatESCPOS,
+	"text/html":                receiptFormatHTML,
+	"application/pdf":          receiptFormatPDF,
+}
+
+type ReceiptHandler struct {
+	uc      *usecase.TransactionUsecase
+	store   receipt.Store
+	paperMM int
+	html    *receipt.HTML
+	baseURL string
+}
+
+// NewReceiptHandler creates a receipt handler printing store on every receipt. paperMM is the
+// default paper width in millimetres (58 or 80). baseURL is the public address of the API used in
+// the QR code of HTML and PDF receipts; if empty it is taken from the request.
+func NewReceiptHandler(uc *usecase.TransactionUsecase, store receipt.Store, paperMM int, html *receipt.HTML, baseURL string) *ReceiptHandler {
+	return &ReceiptHandler{
+		uc:      uc,
+		store:   store,
+		paperMM: paperMM,
+		html:    html,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Receipt handles GET /api/transactions/:id/receipt. The format is taken from the format query
+// (text, escpos, html or pdf) or else negotiated from the Accept header, defaulting to text.
+// Optional width=58|80 overrides the configured paper width of text and escpos receipts; escpos
+// returns raw printer bytes.
+func (h *ReceiptHandler) Receipt(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromSubpath(r.URL.Path, "/api/transactions/", "/receipt")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	h.serve(w, r, func() (*domain.Transaction, error) { return h.uc.GetByID(id) })
+}
+
+// Public handles GET /api/receipts/:token, the receipt link printed in the QR code. It names the
+// transaction by its receipt token rather than its id and takes the same queries as Receipt.
+func (h *ReceiptHandler) Public(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/api/receipts/")
+	if token == "" || strings.Contains(token, "/") {
+		writeError(w, http.StatusBadRequest, "Invalid receipt token")
+		return
+	}
+	h.serve(w, r, func() (*domain.Transaction, error) { return h.uc.GetByReceiptToken(token) })
+}
+
+// serve writes the receipt of the transaction returned by load in the format asked for by r.
+func (h *ReceiptHandler) serve(w http.ResponseWriter, r *http.Request, load func() (*domain.Transaction, error)) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = negotiateReceiptFormat(r.Header.Get("Accept"))
+	}
+	switch format {
+	case receiptFormatText, receiptFormatESCPOS, receiptFormatHTML, receiptFormatPDF:
+	default:
+		writeError(w, http.StatusBadRequest, "format must be text, escpos, html or pdf")
+		return
+	}
+	paperMM := h.paperMM
+	if v := q.Get("width"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || receipt.Columns(n) == 0 {
+			writeError(w, http.StatusBadRequest, "width must be 58 or 80")
+			return
+		}
+		paperMM = n
+	}
+
+	tx, err := load()
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Transaction not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cols := receipt.Columns(paperMM)
+	var body []byte
+	var contentType string
+	switch format {
+	case receiptFormatESCPOS:
+		body, contentType = receipt.ESCPOS(tx, h.store, cols), "application/octet-stream"
+	case receiptFormatHTML:
+		var buf bytes.Buffer
+		if err := h.html.Render(&buf, tx, h.store, h.receiptURL(r, tx)); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		body, contentType = buf.Bytes(), "text/html; charset=utf-8"
+	case receiptFormatPDF:
+		if body, err = receipt.PDF(tx, h.store, h.receiptURL(r, tx)); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		contentType = "application/pdf"
+	default:
+		body, contentType = receipt.Text(tx, h.store, cols), "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// receiptURL returns the public address of the HTML receipt of tx. It is keyed on the receipt
+// token so that it does not reveal how many transactions the store makes.
+func (h *ReceiptHandler) receiptURL(r *http.Request, tx *domain.Transaction) string {
+	base := h.baseURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/api/receipts/" + url.PathEscape(tx.ReceiptToken) + "?format=html"
+}
+
+// negotiateReceiptFormat picks the receipt format with the highest q-value in an Accept header.
+// Wildcards and a missing header select text.
+func negotiateReceiptFormat(accept string) string {
+	best, bestQ := receiptFormatText, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		format, ok := receiptMediaTypes[strings.ToLower(strings.TrimSpace(fields[0]))]
+		if !ok {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			if v, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					quality = f
+				}
+			}
+		}
+		if quality > bestQ {
+			best, bestQ = format, quality
+		}
+	}
+	return best
+}
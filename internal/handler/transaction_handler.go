@@ -2,40 +2,342 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
 	"kasir-api/internal/usecase"
 )
 
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+)
+
 type TransactionHandler struct {
-	uc *usecase.TransactionUsecase
+	uc      *usecase.TransactionUsecase
+	useLock bool
 }
 
-func NewTransactionHandler(uc *usecase.TransactionUsecase) *TransactionHandler {
-	return &TransactionHandler{uc: uc}
+// NewTransactionHandler creates a transaction handler. useLock selects the checkout concurrency
+// strategy (see usecase.TransactionUsecase.Checkout).
+func NewTransactionHandler(uc *usecase.TransactionUsecase, useLock bool) *TransactionHandler {
+	return &TransactionHandler{uc: uc, useLock: useLock}
 }
 
-// HandleCheckout handles POST /api/checkout. Body: {"items": [{"product_id": 1, "quantity": 2}, ...]}.
+// HandleCheckout handles POST /api/checkout.
+// Body: {"items": [{"product_id": 1, "quantity": 2}, ...], "payments": [{"method": "cash", "amount": 100000}, ...]};
+// an item may give "barcode" instead of "product_id".
+// An optional Idempotency-Key header makes retries of the same request return the original transaction.
 func (h *TransactionHandler) HandleCheckout(w http.ResponseWriter, r *http.Request) {
 	var req domain.CheckoutRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	if msg := validateCheckoutRequest(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	key := r.Header.Get(idempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLen {
+		writeError(w, http.StatusBadRequest, "Idempotency-Key too long")
+		return
+	}
+	if key == "" {
+		tx, err := h.uc.Checkout(req, h.useLock)
+		if err != nil {
+			writeCheckoutError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, tx)
+		return
+	}
+
+	tx, replayed, err := h.uc.CheckoutIdempotent(key, req, h.useLock)
+	if err != nil {
+		writeCheckoutError(w, err)
+		return
+	}
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	writeJSON(w, http.StatusCreated, tx)
+}
+
+// Quote handles POST /api/checkout/quote. The body is the same as for POST /api/checkout; the
+// response shows the amounts and warnings the checkout would produce, without saving anything.
+func (h *TransactionHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	var req domain.CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := validateCheckoutRequest(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	quote, err := h.uc.Quote(req)
+	if err != nil {
+		writeCheckoutError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, quote)
+}
+
+// validateCheckoutRequest returns a message describing what is wrong with the shape of req, or "".
+func validateCheckoutRequest(req domain.CheckoutRequest) string {
 	if len(req.Items) == 0 {
-		writeError(w, http.StatusBadRequest, "items required")
+		return "items required"
+	}
+	for _, item := range req.Items {
+		if msg := validateCheckoutItem(item); msg != "" {
+			return msg
+		}
+	}
+	if req.Payment != nil && len(req.Payments) > 0 {
+		return "use either payment or payments, not both"
+	}
+	for _, t := range req.Tenders() {
+		if !domain.ValidPaymentMethod(t.Method) {
+			return "payment method must be one of cash, debit, qris, transfer"
+		}
+	}
+	return ""
+}
+
+// validateCheckoutItem returns a message describing what is wrong with the shape of item, or "".
+// Items given by barcode may leave out the quantity.
+func validateCheckoutItem(item domain.CheckoutItem) string {
+	if (item.ProductID == 0) == (item.Barcode == "") {
+		return "each item needs either product_id or barcode"
+	}
+	if item.Quantity < 0 || (item.Quantity == 0 && item.Barcode == "") {
+		return "quantity must be greater than 0"
+	}
+	return ""
+}
+
+// List handles GET /api/transactions. Optional query: page, limit, from, to (YYYY-MM-DD or RFC3339;
+// a date-only "to" includes that whole day), min_total, max_total and product_id.
+func (h *TransactionHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var f domain.TransactionFilter
+	var ok bool
+
+	if f.Page, ok = parseIntParam(q.Get("page")); !ok {
+		writeError(w, http.StatusBadRequest, "Invalid page")
+		return
+	}
+	if f.Limit, ok = parseIntParam(q.Get("limit")); !ok {
+		writeError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	if f.ProductID, ok = parseIntParam(q.Get("product_id")); !ok {
+		writeError(w, http.StatusBadRequest, "Invalid product_id")
 		return
 	}
-	tx, err := h.uc.Checkout(req.Items, false)
+	if v := q.Get("from"); v != "" {
+		t, ok := parseTimeParam(v, false)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Invalid from, use YYYY-MM-DD or RFC3339")
+			return
+		}
+		f.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, ok := parseTimeParam(v, true)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Invalid to, use YYYY-MM-DD or RFC3339")
+			return
+		}
+		f.To = &t
+	}
+	if v := q.Get("min_total"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid min_total")
+			return
+		}
+		f.MinTotal = &n
+	}
+	if v := q.Get("max_total"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid max_total")
+			return
+		}
+		f.MaxTotal = &n
+	}
+
+	list, err := h.uc.List(f)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			writeError(w, http.StatusNotFound, err.Error())
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetByID handles GET /api/transactions/:id
+func (h *TransactionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromPath(r.URL.Path, "/api/transactions/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	tx, err := h.uc.GetByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Transaction not found")
 			return
 		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	writeJSON(w, http.StatusCreated, tx)
+	writeJSON(w, http.StatusOK, tx)
+}
+
+// Lookup handles GET /api/transactions/lookup?receipt_number=INV/2026/10/18/0001.
+func (h *TransactionHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	number := strings.TrimSpace(r.URL.Query().Get("receipt_number"))
+	if number == "" {
+		writeError(w, http.StatusBadRequest, "receipt_number required")
+		return
+	}
+	tx, err := h.uc.GetByReceiptNumber(number)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Transaction not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, tx)
+}
+
+// Void handles POST /api/transactions/:id/void. Body: {"reason": "...", "performed_by": "..."}.
+func (h *TransactionHandler) Void(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromSubpath(r.URL.Path, "/api/transactions/", "/void")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	var req domain.VoidRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" || strings.TrimSpace(req.PerformedBy) == "" {
+		writeError(w, http.StatusBadRequest, "reason and performed_by required")
+		return
+	}
+	refund, err := h.uc.Void(id, req)
+	if err != nil {
+		writeRefundError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, refund)
+}
+
+// Refund handles POST /api/transactions/:id/refund.
+// Body: {"reason": "...", "performed_by": "...", "items": [{"detail_id": 30, "quantity": 1}, ...]}.
+func (h *TransactionHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDFromSubpath(r.URL.Path, "/api/transactions/", "/refund")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	var req domain.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" || strings.TrimSpace(req.PerformedBy) == "" {
+		writeError(w, http.StatusBadRequest, "reason and performed_by required")
+		return
+	}
+	if len(req.Items) == 0 {
+		writeError(w, http.StatusBadRequest, "items required")
+		return
+	}
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			writeError(w, http.StatusBadRequest, "quantity must be greater than 0")
+			return
+		}
+	}
+	refund, err := h.uc.Refund(id, req)
+	if err != nil {
+		writeRefundError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, refund)
+}
+
+// writeRefundError maps void and refund errors to HTTP responses.
+func writeRefundError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "Transaction not found")
+	case errors.Is(err, repository.ErrTransactionVoided):
+		writeError(w, http.StatusConflict, "Transaction already voided")
+	case errors.Is(err, usecase.ErrVoidWindowClosed):
+		writeError(w, http.StatusUnprocessableEntity, "Transactions can only be voided on the day they were made; use refund instead")
+	case errors.Is(err, repository.ErrInvalidRefund):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, repository.ErrSerialization):
+		writeError(w, http.StatusConflict, "Refund conflicted with a concurrent update, please retry")
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// parseTimeParam parses an RFC3339 timestamp or a YYYY-MM-DD date in local time.
+// With endOfDay, a date-only value is moved to the start of the following day.
+func parseTimeParam(v string, endOfDay bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+// writeCheckoutError maps checkout errors to HTTP responses.
+func writeCheckoutError(w http.ResponseWriter, err error) {
+	var stockErr *repository.ErrInsufficientStock
+	if errors.As(err, &stockErr) {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"status":  "error",
+			"message": "Insufficient stock",
+			"items":   stockErr.Items,
+		})
+		return
+	}
+	switch {
+	case errors.Is(err, usecase.ErrIdempotencyKeyReused):
+		writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+	case errors.Is(err, usecase.ErrIdempotencyKeyInProgress):
+		writeError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+	case errors.Is(err, repository.ErrSerialization):
+		writeError(w, http.StatusConflict, "Checkout conflicted with a concurrent checkout, please retry")
+	case errors.Is(err, usecase.ErrInvalidPayment), errors.Is(err, usecase.ErrInvalidDiscount),
+		errors.Is(err, usecase.ErrProductInactive), errors.Is(err, usecase.ErrInvalidUnit),
+		errors.Is(err, usecase.ErrVariantRequired), errors.Is(err, usecase.ErrInvalidModifier):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
 }
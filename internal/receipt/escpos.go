@@ -0,0 +1,56 @@
+package receipt
+
+import (
+	"bytes"
+
+	"kasir-api/internal/domain"
+)
+
+// ESC/POS commands used by ESCPOS.
+var (
+	escInit        = []byte{0x1b, '@'}
+	escAlignLeft   = []byte{0x1b, 'a', 0}
+	escAlignCenter = []byte{0x1b, 'a', 1}
+	escBoldOn      = []byte{0x1b, 'E', 1}
+	escBoldOff     = []byte{0x1b, 'E', 0}
+	escSizeNormal  = []byte{0x1d, '!', 0x00}
+	escSizeTall    = []byte{0x1d, '!', 0x01}
+	escFeedAndCut  = []byte{0x1d, 'V', 'A', 3} // feed 3 lines, then partial cut
+)
+
+// ESCPOS renders tx as raw ESC/POS commands for a thermal printer with cols characters per line,
+// ending with a paper cut. Characters outside ASCII are printed as '?', since printers default to
+// a code page that does not cover them.
+func ESCPOS(tx *domain.Transaction, store Store, cols int) []byte {
+	var b bytes.Buffer
+	b.Write(escInit)
+	for _, l := range layout(tx, store, cols) {
+		if l.align == alignCenter {
+			b.Write(escAlignCenter)
+		}
+		if l.bold {
+			b.Write(escBoldOn)
+		}
+		if l.large {
+			b.Write(escSizeTall)
+		}
+		for _, r := range l.text {
+			if r > 0x7e || r < 0x20 {
+				r = '?'
+			}
+			b.WriteByte(byte(r))
+		}
+		b.WriteByte('\n')
+		if l.large {
+			b.Write(escSizeNormal)
+		}
+		if l.bold {
+			b.Write(escBoldOff)
+		}
+		if l.align == alignCenter {
+			b.Write(escAlignLeft)
+		}
+	}
+	b.Write(escFeedAndCut)
+	return b.Bytes()
+}
@@ -0,0 +1,91 @@
+package receipt
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"kasir-api/internal/domain"
+)
+
+// htmlTemplateName is the file name of the HTML receipt template, both embedded and in an
+// override directory.
+const htmlTemplateName = "receipt.html"
+
+//go:embed templates/receipt.html
+var defaultTemplates embed.FS
+
+// HTMLView is the data passed to the HTML receipt template.
+type HTMLView struct {
+	Store    Store
+	LogoURI  template.URL
+	Number   string
+	Date     string
+	Banner   string
+	URL      string
+	QRCode   template.HTML // inline SVG linking to URL; empty if URL is
+	Items    []domain.TransactionDetail
+	Totals   []AmountLine
+	Payments []AmountLine
+}
+
+// HTML renders transactions with an html/template.
+type HTML struct {
+	tmpl *template.Template
+}
+
+// NewHTML loads the HTML receipt template. If dir is non-empty and contains receipt.html, that
+// template is used instead of the built-in one. Templates may use the rupiah and percent functions.
+func NewHTML(dir string) (*HTML, error) {
+	var src fs.FS = defaultTemplates
+	name := "templates/" + htmlTemplateName
+	if dir != "" {
+		path := filepath.Join(dir, htmlTemplateName)
+		if _, err := os.Stat(path); err == nil {
+			src, name = os.DirFS(dir), htmlTemplateName
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	tmpl, err := template.New(htmlTemplateName).Funcs(template.FuncMap{
+		"rupiah":   Rupiah,
+		"qty":      Qty,
+		"lineQty":  LineQty,
+		"modifier": Modifier,
+		"percent":  Percent,
+	}).ParseFS(src, name)
+	if err != nil {
+		return nil, err
+	}
+	return &HTML{tmpl: tmpl}, nil
+}
+
+// Render writes tx as an HTML receipt. url is the public address of the receipt, encoded in a QR
+// code; no QR code is drawn if it is empty.
+func (h *HTML) Render(w io.Writer, tx *domain.Transaction, store Store, url string) error {
+	view := HTMLView{
+		Store:    store,
+		Number:   Number(tx),
+		Date:     tx.CreatedAt.Local().Format("02/01/2006 15:04"),
+		Banner:   statusBanner(tx),
+		URL:      url,
+		Items:    tx.Details,
+		Totals:   totalLines(tx),
+		Payments: paymentLines(tx),
+	}
+	if store.Logo != nil {
+		view.LogoURI = template.URL(store.Logo.DataURI())
+	}
+	if url != "" {
+		qr, err := EncodeQR(url)
+		if err != nil {
+			return err
+		}
+		view.QRCode = template.HTML(qr.SVG(4))
+	}
+	return h.tmpl.ExecuteTemplate(w, htmlTemplateName, view)
+}
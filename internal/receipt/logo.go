@@ -0,0 +1,37 @@
+package receipt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register decoders for LoadLogo
+	_ "image/png"
+	"net/http"
+	"os"
+)
+
+// Logo is a store logo printed on HTML and PDF receipts.
+type Logo struct {
+	image image.Image
+	data  []byte
+	mime  string
+}
+
+// LoadLogo reads a PNG or JPEG logo from path.
+func LoadLogo(path string) (*Logo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode logo %s: %w", path, err)
+	}
+	return &Logo{image: img, data: data, mime: http.DetectContentType(data)}, nil
+}
+
+// DataURI returns the logo as a data: URI for embedding in HTML.
+func (l *Logo) DataURI() string {
+	return "data:" + l.mime + ";base64," + base64.StdEncoding.EncodeToString(l.data)
+}
@@ -0,0 +1,188 @@
+package receipt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"strings"
+
+	"kasir-api/internal/domain"
+)
+
+// PDF receipt geometry, in points. The page is as wide as 80mm paper and as tall as its content.
+const (
+	pdfPageWidth  = 226.8
+	pdfMargin     = 17.4
+	pdfFontSize   = 8.0
+	pdfCharWidth  = pdfFontSize * 0.6 // Courier advance width
+	pdfLeading    = 10.0
+	pdfColumns    = 40
+	pdfLogoWidth  = 120.0
+	pdfLogoHeight = 60.0
+	pdfQRModule   = 2.5
+)
+
+// PDF renders tx as a single-page PDF receipt using the built-in Courier fonts, with the store
+// logo on top and, if url is non-empty, a QR code linking to url at the bottom.
+func PDF(tx *domain.Transaction, store Store, url string) ([]byte, error) {
+	var qr *QR
+	if url != "" {
+		var err error
+		if qr, err = EncodeQR(url); err != nil {
+			return nil, err
+		}
+	}
+	lines := layout(tx, store, pdfColumns)
+
+	height := 2*pdfMargin + float64(len(lines))*pdfLeading
+	var logoW, logoH float64
+	if store.Logo != nil {
+		b := store.Logo.image.Bounds()
+		scale := min(pdfLogoWidth/float64(b.Dx()), pdfLogoHeight/float64(b.Dy()))
+		logoW, logoH = float64(b.Dx())*scale, float64(b.Dy())*scale
+		height += logoH + pdfLeading
+	}
+	if qr != nil {
+		height += float64(qr.Size+8)*pdfQRModule + pdfLeading
+	}
+
+	var content bytes.Buffer
+	y := height - pdfMargin
+	if store.Logo != nil {
+		y -= logoH
+		fmt.Fprintf(&content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im1 Do Q\n", logoW, logoH, (pdfPageWidth-logoW)/2, y)
+		y -= pdfLeading
+	}
+	for _, l := range lines {
+		y -= pdfLeading
+		x := pdfMargin
+		if l.align == alignCenter {
+			x += float64(pdfColumns-len([]rune(l.text))) * pdfCharWidth / 2
+		}
+		font := "F1"
+		if l.bold || l.large {
+			font = "F2"
+		}
+		fmt.Fprintf(&content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, pdfFontSize, x, y+2, pdfString(l.text))
+	}
+	if qr != nil {
+		dim := float64(qr.Size) * pdfQRModule
+		left := (pdfPageWidth - dim) / 2
+		top := y - pdfLeading - 4*pdfQRModule
+		content.WriteString("0 g\n")
+		for row, modules := range qr.Modules {
+			for col, dark := range modules {
+				if dark {
+					fmt.Fprintf(&content, "%.2f %.2f %.2f %.2f re\n",
+						left+float64(col)*pdfQRModule, top-float64(row+1)*pdfQRModule, pdfQRModule, pdfQRModule)
+				}
+			}
+		}
+		content.WriteString("f\n")
+	}
+
+	var doc pdfWriter
+	doc.object("<< /Type /Catalog /Pages 2 0 R >>")
+	doc.object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	resources := "/Font << /F1 4 0 R /F2 5 0 R >>"
+	if store.Logo != nil {
+		resources += " /XObject << /Im1 7 0 R >>"
+	}
+	doc.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << %s >> /Contents 6 0 R >>",
+		pdfPageWidth, height, resources))
+	doc.object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	doc.object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+	doc.stream("", content.Bytes())
+	if store.Logo != nil {
+		b := store.Logo.image.Bounds()
+		pixels, err := deflate(rgb(store.Logo.image))
+		if err != nil {
+			return nil, err
+		}
+		doc.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+			b.Dx(), b.Dy()), pixels)
+	}
+	return doc.finish(), nil
+}
+
+// pdfWriter builds a PDF file object by object; objects are numbered from 1 in the order added.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *pdfWriter) start() {
+	if w.buf.Len() == 0 {
+		w.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	}
+	w.offsets = append(w.offsets, w.buf.Len())
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", len(w.offsets))
+}
+
+func (w *pdfWriter) object(body string) {
+	w.start()
+	w.buf.WriteString(body)
+	w.buf.WriteString("\nendobj\n")
+}
+
+// stream adds a stream object; dict holds extra dictionary entries besides /Length.
+func (w *pdfWriter) stream(dict string, data []byte) {
+	w.start()
+	fmt.Fprintf(&w.buf, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+func (w *pdfWriter) finish() []byte {
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, off := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, xref)
+	return w.buf.Bytes()
+}
+
+// pdfString escapes s for a PDF literal string. Characters outside Latin-1 become '?'.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+// rgb returns the pixels of img as 8-bit RGB triples, blending transparency onto white.
+func rgb(img image.Image) []byte {
+	b := img.Bounds()
+	out := make([]byte, 0, b.Dx()*b.Dy()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			white := 0xffff - a
+			out = append(out, byte((r+white)>>8), byte((g+white)>>8), byte((bl+white)>>8))
+		}
+	}
+	return out
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
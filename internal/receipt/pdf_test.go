@@ -0,0 +1,67 @@
+package receipt
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestPDFStructure(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		img.Set(x, 10, color.RGBA{R: 200, A: 255})
+	}
+	store := goldenStore
+	store.Logo = &Logo{image: img}
+	doc, err := PDF(goldenTransaction(), store, "https://kasir.example/r/INV-20260105-0007")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) {
+		t.Errorf("missing PDF header: %q", doc[:16])
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(doc)
+	if m == nil {
+		t.Fatal("missing startxref trailer")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+
+	table := regexp.MustCompile(`^xref\n0 (\d+)\n0000000000 65535 f \n`).FindSubmatch(doc[xref:])
+	if table == nil {
+		t.Fatal("malformed xref header")
+	}
+	size, _ := strconv.Atoi(string(table[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(doc[xref:], -1)
+	if len(entries) != size-1 {
+		t.Fatalf("xref has %d entries, header says %d objects", len(entries), size-1)
+	}
+	if !bytes.Contains(doc, []byte("/Size "+strconv.Itoa(size)+" ")) {
+		t.Errorf("trailer /Size does not match xref size %d", size)
+	}
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		want := strconv.Itoa(i+1) + " 0 obj\n"
+		if !bytes.HasPrefix(doc[off:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q, want %q", i+1, doc[off:min(off+12, len(doc))], want)
+		}
+	}
+
+	streams := regexp.MustCompile(`/Length (\d+) >>\nstream\n`).FindAllSubmatchIndex(doc, -1)
+	if len(streams) != 2 {
+		t.Fatalf("found %d streams, want content and logo", len(streams))
+	}
+	for _, s := range streams {
+		length, _ := strconv.Atoi(string(doc[s[2]:s[3]]))
+		end := s[1] + length
+		if !bytes.HasPrefix(doc[end:], []byte("\nendstream\nendobj\n")) {
+			t.Errorf("/Length %d does not end at endstream", length)
+		}
+	}
+}
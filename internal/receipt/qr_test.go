@@ -0,0 +1,338 @@
+package receipt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Byte-mode capacity at level M of versions 1-10 and their error correction block layout, taken
+// from the QR code specification independently of qrVersions.
+var (
+	specCapacity = []int{0, 14, 26, 42, 62, 84, 106, 122, 152, 180, 213}
+	specBlocks   = []struct {
+		ec     int
+		groups [][2]int // {number of blocks, data codewords per block}
+	}{
+		1:  {10, [][2]int{{1, 16}}},
+		2:  {16, [][2]int{{1, 28}}},
+		3:  {26, [][2]int{{1, 44}}},
+		4:  {18, [][2]int{{2, 32}}},
+		5:  {24, [][2]int{{2, 43}}},
+		6:  {16, [][2]int{{4, 27}}},
+		7:  {18, [][2]int{{4, 31}}},
+		8:  {22, [][2]int{{2, 38}, {2, 39}}},
+		9:  {22, [][2]int{{3, 36}, {2, 37}}},
+		10: {26, [][2]int{{4, 43}, {1, 44}}},
+	}
+	specAlignment = [][]int{2: {6, 18}, 3: {6, 22}, 4: {6, 26}, 5: {6, 30}, 6: {6, 34},
+		7: {6, 22, 38}, 8: {6, 24, 42}, 9: {6, 26, 46}, 10: {6, 28, 50}}
+	// Format information of level M for masks 0-7, after the 0x5412 mask.
+	specFormatM = []string{
+		"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+	}
+	// Version information of versions 7-10.
+	specVersionInfo = map[int]string{
+		7:  "000111110010010100",
+		8:  "001000010110111100",
+		9:  "001001101010011001",
+		10: "001010010011010011",
+	}
+)
+
+func TestReedSolomonVectors(t *testing.T) {
+	// Version 1-M examples from ISO/IEC 18004 ("01234567", numeric) and the "HELLO WORLD"
+	// alphanumeric example commonly used to check encoders.
+	tests := []struct {
+		data, ec []byte
+	}{
+		{
+			data: []byte{0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11},
+			ec:   []byte{0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55},
+		},
+		{
+			data: []byte{0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11},
+			ec:   []byte{0xc4, 0x23, 0x27, 0x77, 0xeb, 0xd7, 0xe7, 0xe2, 0x5d, 0x17},
+		},
+	}
+	for _, tt := range tests {
+		if got := reedSolomon(tt.data, len(tt.ec)); !bytes.Equal(got, tt.ec) {
+			t.Errorf("reedSolomon(% x) = % x, want % x", tt.data, got, tt.ec)
+		}
+	}
+}
+
+func TestQRRoundTrip(t *testing.T) {
+	for n := 1; n <= specCapacity[10]; n++ {
+		data := strings.Repeat("https://kasir.example/r/INV-0001?", 7)[:n]
+		q, err := EncodeQR(data)
+		if err != nil {
+			t.Fatalf("EncodeQR(%d bytes): %v", n, err)
+		}
+		want := 1
+		for specCapacity[want] < n {
+			want++
+		}
+		if q.Size != 17+4*want {
+			t.Fatalf("%d bytes: size %d, want version %d (%d)", n, q.Size, want, 17+4*want)
+		}
+		got, err := decodeQR(q)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if got != data {
+			t.Fatalf("%d bytes: decoded %q, want %q", n, got, data)
+		}
+	}
+
+	// A damaged data module must be caught, so the checks above cannot pass vacuously.
+	q, err := EncodeQR("https://kasir.example/r/INV-0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Modules[q.Size-1][q.Size-1] = !q.Modules[q.Size-1][q.Size-1]
+	if _, err := decodeQR(q); err == nil {
+		t.Error("decodeQR accepted a symbol with a flipped data module")
+	}
+
+	if _, err := EncodeQR(strings.Repeat("x", specCapacity[10]+1)); !errors.Is(err, ErrQRTooLong) {
+		t.Errorf("EncodeQR(%d bytes) error = %v, want ErrQRTooLong", specCapacity[10]+1, err)
+	}
+}
+
+// decodeQR reads back a byte-mode, level M symbol using only the specification: it checks the
+// finder, timing, format and version patterns, unmasks the data, verifies every block's error
+// correction codewords and returns the encoded bytes.
+func decodeQR(q *QR) (string, error) {
+	version := (q.Size - 17) / 4
+	n := q.Size
+	at := func(x, y int) bool { return q.Modules[y][x] }
+
+	for _, c := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				d := max(abs(dx-3), abs(dy-3))
+				if at(c[0]+dx, c[1]+dy) != (d != 2) {
+					return "", fmt.Errorf("finder at %v is malformed", c)
+				}
+			}
+		}
+	}
+	for i := 8; i < n-8; i++ {
+		if at(i, 6) != (i%2 == 0) || at(6, i) != (i%2 == 0) {
+			return "", fmt.Errorf("timing pattern is malformed at %d", i)
+		}
+	}
+
+	// Format information, both copies, bit 14 first.
+	var first, second strings.Builder
+	for i := 14; i >= 0; i-- {
+		var x, y int
+		switch {
+		case i <= 5:
+			x, y = 8, i
+		case i == 6:
+			x, y = 8, 7
+		case i == 7:
+			x, y = 8, 8
+		case i == 8:
+			x, y = 7, 8
+		default:
+			x, y = 14-i, 8
+		}
+		first.WriteByte("01"[b2i(at(x, y))])
+		if i < 8 {
+			x, y = n-1-i, 8
+		} else {
+			x, y = 8, n-15+i
+		}
+		second.WriteByte("01"[b2i(at(x, y))])
+	}
+	if first.String() != second.String() {
+		return "", fmt.Errorf("format copies differ: %s and %s", first.String(), second.String())
+	}
+	mask := -1
+	for m, f := range specFormatM {
+		if f == first.String() {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		return "", fmt.Errorf("format %s is not level M", first.String())
+	}
+	if !at(8, n-8) {
+		return "", fmt.Errorf("dark module missing")
+	}
+
+	if want, ok := specVersionInfo[version]; ok {
+		var tr, bl strings.Builder
+		for i := 17; i >= 0; i-- {
+			tr.WriteByte("01"[b2i(at(n-11+i%3, i/3))])
+			bl.WriteByte("01"[b2i(at(i/3, n-11+i%3))])
+		}
+		if tr.String() != want || bl.String() != want {
+			return "", fmt.Errorf("version information %s / %s, want %s", tr.String(), bl.String(), want)
+		}
+	}
+
+	// Function modules, which carry no data.
+	function := make([][]bool, n)
+	for y := range function {
+		function[y] = make([]bool, n)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				function[y][x] = true
+			}
+		}
+	}
+	fill(0, 0, 9, 9)
+	fill(n-8, 0, 8, 9)
+	fill(0, n-8, 9, 8)
+	fill(6, 0, 1, n)
+	fill(0, 6, n, 1)
+	pos := specAlignment[version]
+	for i, x := range pos {
+		for j, y := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == len(pos)-1) || (i == len(pos)-1 && j == 0) {
+				continue
+			}
+			fill(x-2, y-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		fill(n-11, 0, 3, 6)
+		fill(0, n-11, 6, 3)
+	}
+
+	masks := []func(x, y int) bool{
+		func(x, y int) bool { return (x+y)%2 == 0 },
+		func(x, y int) bool { return y%2 == 0 },
+		func(x, y int) bool { return x%3 == 0 },
+		func(x, y int) bool { return (x+y)%3 == 0 },
+		func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+		func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+		func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+		func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+	}
+	var bits []bool
+	upward := true
+	for right := n - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for i := 0; i < n; i++ {
+			y := i
+			if upward {
+				y = n - 1 - i
+			}
+			for _, x := range []int{right, right - 1} {
+				if !function[y][x] {
+					bits = append(bits, at(x, y) != masks[mask](x, y))
+				}
+			}
+		}
+		upward = !upward
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for j := 0; j < 8; j++ {
+			codewords[i] = codewords[i]<<1 | byte(b2i(bits[8*i+j]))
+		}
+	}
+
+	// De-interleave the blocks and check their error correction.
+	spec := specBlocks[version]
+	var sizes []int
+	for _, g := range spec.groups {
+		for k := 0; k < g[0]; k++ {
+			sizes = append(sizes, g[1])
+		}
+	}
+	blocks := make([][]byte, len(sizes))
+	i := 0
+	for col := 0; col < sizes[len(sizes)-1]; col++ {
+		for b, size := range sizes {
+			if col < size {
+				blocks[b] = append(blocks[b], codewords[i])
+				i++
+			}
+		}
+	}
+	for col := 0; col < spec.ec; col++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[i])
+			i++
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		if !rsValid(block, spec.ec) {
+			return "", fmt.Errorf("block %d fails its error correction check", b)
+		}
+		data = append(data, block[:sizes[b]]...)
+	}
+
+	// Byte mode segment.
+	read := func(pos, n int) int {
+		v := 0
+		for k := 0; k < n; k++ {
+			v = v<<1 | int(data[(pos+k)/8]>>(7-(pos+k)%8)&1)
+		}
+		return v
+	}
+	if mode := read(0, 4); mode != 0b0100 {
+		return "", fmt.Errorf("mode %04b is not byte mode", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	count := read(4, countBits)
+	out := make([]byte, count)
+	for k := range out {
+		out[k] = byte(read(4+countBits+8*k, 8))
+	}
+	return string(out), nil
+}
+
+// rsValid reports whether every syndrome of block, with ec error correction codewords, is zero,
+// using GF(256) log tables built here rather than gfMul.
+func rsValid(block []byte, ec int) bool {
+	var exp [512]byte
+	var log [256]int
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = byte(x), byte(x)
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for k := 0; k < ec; k++ {
+		var s byte
+		for _, c := range block {
+			// s = s*alpha^k + c
+			if s != 0 {
+				s = exp[log[s]+k]
+			}
+			s ^= c
+		}
+		if s != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,295 @@
+// Package receipt renders transactions as printable receipts.
+package receipt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"kasir-api/internal/domain"
+)
+
+// Paper widths in millimetres and the number of characters per line they fit in the printer's
+// default font.
+const (
+	Paper58mm = 58
+	Paper80mm = 80
+)
+
+// Columns returns the characters per line for a paper width in millimetres, or 0 if unsupported.
+func Columns(paperMM int) int {
+	switch paperMM {
+	case Paper58mm:
+		return 32
+	case Paper80mm:
+		return 48
+	}
+	return 0
+}
+
+// Store is the header and footer printed on every receipt. Logo is only used by the HTML and PDF
+// receipts and may be nil.
+type Store struct {
+	Name    string
+	Address string
+	Phone   string
+	NPWP    string
+	Footer  string
+	Logo    *Logo
+}
+
+type align int
+
+const (
+	alignLeft align = iota
+	alignCenter
+)
+
+// line is one printed line of a receipt. Text is already laid out for the receipt width.
+type line struct {
+	text  string
+	align align
+	bold  bool
+	large bool // double height; the width is unchanged
+}
+
+var paymentLabels = map[string]string{
+	domain.PaymentMethodCash:     "Tunai",
+	domain.PaymentMethodDebit:    "Debit",
+	domain.PaymentMethodQRIS:     "QRIS",
+	domain.PaymentMethodTransfer: "Transfer",
+}
+
+// AmountLine is a labelled amount in the totals or payments section of a receipt.
+type AmountLine struct {
+	Label  string
+	Amount int
+	Bold   bool
+}
+
+// totalLines returns the subtotal, discount, service charge, tax and total of tx.
+func totalLines(tx *domain.Transaction) []AmountLine {
+	out := []AmountLine{{Label: "Subtotal", Amount: tx.GrossAmount}}
+	if tx.DiscountAmount > 0 {
+		out = append(out, AmountLine{Label: "Diskon", Amount: -tx.DiscountAmount})
+	}
+	if tx.ServiceChargeAmount > 0 {
+		out = append(out, AmountLine{Label: "Service " + Percent(tx.ServiceChargeRate), Amount: tx.ServiceChargeAmount})
+	}
+	if tx.TaxAmount > 0 {
+		label := "PPN " + Percent(tx.TaxRate)
+		if tx.TaxInclusive {
+			label += " (termasuk)"
+		}
+		out = append(out, AmountLine{Label: label, Amount: tx.TaxAmount})
+	}
+	return append(out, AmountLine{Label: "TOTAL", Amount: tx.TotalAmount, Bold: true})
+}
+
+// paymentLines returns the tenders of tx followed by the change, or nothing if tx has no payments.
+func paymentLines(tx *domain.Transaction) []AmountLine {
+	if len(tx.Payments) == 0 {
+		return nil
+	}
+	var out []AmountLine
+	for _, p := range tx.Payments {
+		label, ok := paymentLabels[p.Method]
+		if !ok {
+			label = p.Method
+		}
+		out = append(out, AmountLine{Label: label, Amount: p.Tendered})
+	}
+	return append(out, AmountLine{Label: "Kembali", Amount: tx.Change})
+}
+
+// statusBanner returns the banner printed on receipts of voided or refunded transactions, or "".
+func statusBanner(tx *domain.Transaction) string {
+	switch tx.Status {
+	case domain.TransactionStatusVoided:
+		return "*** VOID ***"
+	case domain.TransactionStatusRefunded, domain.TransactionStatusPartiallyRefunded:
+		return "*** REFUND ***"
+	}
+	return ""
+}
+
+// layout lays tx out as receipt lines of at most cols characters.
+func layout(tx *domain.Transaction, store Store, cols int) []line {
+	var out []line
+	rule := line{text: strings.Repeat("-", cols)}
+	center := func(text string, bold, large bool) {
+		for _, t := range wrap(text, cols) {
+			out = append(out, line{text: t, align: alignCenter, bold: bold, large: large})
+		}
+	}
+	left := func(text string) {
+		for _, t := range wrap(text, cols) {
+			out = append(out, line{text: t})
+		}
+	}
+	pair := func(a AmountLine) {
+		out = append(out, line{text: columns(a.Label, Rupiah(a.Amount), cols), bold: a.Bold})
+	}
+
+	if store.Name != "" {
+		center(store.Name, true, true)
+	}
+	if store.Address != "" {
+		center(store.Address, false, false)
+	}
+	if store.Phone != "" {
+		center("Telp. "+store.Phone, false, false)
+	}
+	if store.NPWP != "" {
+		center("NPWP "+store.NPWP, false, false)
+	}
+	out = append(out, rule)
+	out = append(out, line{text: "No. " + Number(tx)})
+	out = append(out, line{text: tx.CreatedAt.Local().Format("02/01/2006 15:04")})
+	if banner := statusBanner(tx); banner != "" {
+		center(banner, true, false)
+	}
+	out = append(out, rule)
+
+	for _, d := range tx.Details {
+		left(d.ProductName)
+		for _, m := range d.Modifiers {
+			for i, t := range wrap(Modifier(m), cols-4) {
+				indent := "    "
+				if i == 0 {
+					indent = "  + "
+				}
+				out = append(out, line{text: indent + t})
+			}
+		}
+		out = append(out, line{text: columns(
+			fmt.Sprintf("  %s x %s", LineQty(d), Rupiah(d.UnitPrice)), Rupiah(d.GrossAmount), cols)})
+	}
+	out = append(out, rule)
+
+	for _, a := range totalLines(tx) {
+		pair(a)
+	}
+	if payments := paymentLines(tx); len(payments) > 0 {
+		out = append(out, rule)
+		for _, a := range payments {
+			pair(a)
+		}
+	}
+
+	if store.Footer != "" {
+		out = append(out, rule)
+		center(store.Footer, false, false)
+	}
+	return out
+}
+
+// Text renders tx as a plain-text receipt cols characters wide, one line per row.
+func Text(tx *domain.Transaction, store Store, cols int) []byte {
+	var b strings.Builder
+	for _, l := range layout(tx, store, cols) {
+		text := l.text
+		if l.align == alignCenter {
+			text = strings.Repeat(" ", (cols-utf8.RuneCountInString(text))/2) + text
+		}
+		b.WriteString(text)
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+// Number returns the receipt number of tx, or its ID for transactions made before receipts were numbered.
+func Number(tx *domain.Transaction) string {
+	if tx.ReceiptNumber != "" {
+		return tx.ReceiptNumber
+	}
+	return strconv.Itoa(tx.ID)
+}
+
+// Rupiah formats an amount with dot thousands separators, e.g. 1.250.000.
+func Rupiah(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	s := strconv.Itoa(amount)
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String()
+}
+
+// Qty formats a quantity with a decimal comma, e.g. "2" or "0,453", so it cannot be mistaken for
+// a rupiah amount with thousands dots.
+func Qty(q domain.Quantity) string {
+	return strings.Replace(q.String(), ".", ",", 1)
+}
+
+// LineQty formats the quantity of a line with the unit it was sold in, e.g. "2 slop" or "0,453 kg".
+func LineQty(d domain.TransactionDetail) string {
+	if d.Unit == "" {
+		return Qty(d.Quantity)
+	}
+	return Qty(d.Quantity) + " " + d.Unit
+}
+
+// Modifier formats a modifier picked on a line with its price, e.g. "Extra shot (5.000)", or just
+// its name if it is free.
+func Modifier(m domain.DetailModifier) string {
+	if m.Harga == 0 {
+		return m.Nama
+	}
+	return m.Nama + " (" + Rupiah(m.Harga) + ")"
+}
+
+// Percent formats a rate such as 11 or 2.5 as "11%" or "2.5%".
+func Percent(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64) + "%"
+}
+
+// columns puts label on the left and value on the right of a cols-wide line. A label that does
+// not fit is cut short.
+func columns(label, value string, cols int) string {
+	space := max(cols-utf8.RuneCountInString(value)-1, 0)
+	if utf8.RuneCountInString(label) > space {
+		label = string([]rune(label)[:space])
+	}
+	pad := max(cols-utf8.RuneCountInString(label)-utf8.RuneCountInString(value), 1)
+	return label + strings.Repeat(" ", pad) + value
+}
+
+// wrap breaks text into lines of at most cols characters at spaces, splitting longer words.
+func wrap(text string, cols int) []string {
+	var out []string
+	cur := ""
+	for _, word := range strings.Fields(text) {
+		for utf8.RuneCountInString(word) > cols {
+			if cur != "" {
+				out = append(out, cur)
+				cur = ""
+			}
+			r := []rune(word)
+			out = append(out, string(r[:cols]))
+			word = string(r[cols:])
+		}
+		switch {
+		case cur == "":
+			cur = word
+		case utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) <= cols:
+			cur += " " + word
+		default:
+			out = append(out, cur)
+			cur = word
+		}
+	}
+	if cur != "" {
+		out = append(out, cur)
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package receipt
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kasir-api/internal/domain"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenTransaction is a sale with a line discount, a cart discount, service charge, tax and a
+// payment split over QRIS and cash.
+func goldenTransaction() *domain.Transaction {
+	return &domain.Transaction{
+		ID:            42,
+		ReceiptNumber: "INV-20260105-0007",
+		CreatedAt:     time.Date(2026, 1, 5, 14, 30, 0, 0, time.Local),
+		Status:        domain.TransactionStatusCompleted,
+		Details: []domain.TransactionDetail{
+			{
+				ProductID:      1,
+				ProductName:    "Kopi Susu Gula Aren Ukuran Besar Dengan Es",
+				UnitPrice:      25000,
+				Quantity:       domain.Units(2),
+				UnitFactor:     domain.Units(1),
+				GrossAmount:    50000,
+				DiscountAmount: 9500,
+				Subtotal:       40500,
+			},
+			{
+				ProductID:      2,
+				ProductName:    "Roti Bakar",
+				UnitPrice:      20000,
+				Quantity:       domain.Units(1),
+				UnitFactor:     domain.Units(1),
+				GrossAmount:    20000,
+				DiscountAmount: 2000,
+				Subtotal:       18000,
+			},
+		},
+		GrossAmount:         70000,
+		DiscountAmount:      11500,
+		CartDiscountAmount:  6500,
+		NetAmount:           58500,
+		ServiceChargeRate:   5,
+		ServiceChargeAmount: 2925,
+		TaxRate:             11,
+		TaxAmount:           6757,
+		TotalAmount:         68182,
+		Payments: []domain.Payment{
+			{Method: domain.PaymentMethodQRIS, Amount: 50000, Tendered: 50000},
+			{Method: domain.PaymentMethodCash, Amount: 18182, Tendered: 20000, Change: 1818},
+		},
+		Change: 1818,
+	}
+}
+
+var goldenStore = Store{
+	Name:    "Warung Kopi Senja",
+	Address: "Jl. Merdeka No. 17, Bandung",
+	Phone:   "022-1234567",
+	NPWP:    "01.234.567.8-901.000",
+	Footer:  "Terima kasih atas kunjungan Anda",
+}
+
+func TestGolden(t *testing.T) {
+	formats := []struct {
+		name   string
+		render func(*domain.Transaction, Store, int) []byte
+	}{
+		{"text", Text},
+		{"escpos", ESCPOS},
+	}
+	for _, f := range formats {
+		for _, paper := range []int{Paper58mm, Paper80mm} {
+			name := fmt.Sprintf("%s_%dmm", f.name, paper)
+			t.Run(name, func(t *testing.T) {
+				got := f.render(goldenTransaction(), goldenStore, Columns(paper))
+				path := filepath.Join("testdata", name+".golden")
+				if *update {
+					if err := os.WriteFile(path, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("%v (run go test ./internal/receipt -update to create it)", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s differs from %s:\n%s", name, path, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"kasir-api/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CartPG is a PostgreSQL implementation of CartRepository.
+type CartPG struct {
+	pool *pgxpool.Pool
+}
+
+// NewCartPG creates a new PostgreSQL cart repository.
+func NewCartPG(pool *pgxpool.Pool) *CartPG {
+	return &CartPG{pool: pool}
+}
+
+// Create inserts a cart with its items, reserving stock if c.ReserveStock is set.
+// Items for the same product, unit and modifiers are merged into one line.
+func (r *CartPG) Create(c domain.Cart, ttl time.Duration) (*domain.Cart, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	discountType, discountValue := discountColumns(c.Discount)
+	var id int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO carts (reserve_stock, discount_type, discount_value, expires_at)
+		 VALUES ($1, $2, $3, now() + make_interval(secs => $4)) RETURNING id`,
+		c.ReserveStock, discountType, discountValue, ttl.Seconds()).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	type lineKey struct {
+		productID int
+		unit      string
+		modifiers string
+	}
+	merged := make(map[lineKey]domain.CheckoutItem)
+	var order []lineKey
+	for _, item := range c.Items {
+		k := lineKey{item.ProductID, item.Unit, fmt.Sprint(item.Modifiers)}
+		m, ok := merged[k]
+		if !ok {
+			order = append(order, k)
+			m = domain.CheckoutItem{ProductID: item.ProductID, Unit: item.Unit, Modifiers: item.Modifiers}
+		}
+		m.Quantity += item.Quantity
+		if item.Discount != nil {
+			m.Discount = item.Discount
+		}
+		merged[k] = m
+	}
+	// Reserve in product id order, like checkout, so concurrent reservations do not deadlock.
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].productID != order[j].productID {
+			return order[i].productID < order[j].productID
+		}
+		if order[i].unit != order[j].unit {
+			return order[i].unit < order[j].unit
+		}
+		return order[i].modifiers < order[j].modifiers
+	})
+	for _, k := range order {
+		if err := setCartLine(ctx, tx, id, c.ReserveStock, nil, merged[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, mapTxError(err)
+	}
+	return r.GetByID(id)
+}
+
+// GetByID returns a cart with its items and current product names, or ErrNotFound.
+func (r *CartPG) GetByID(id int) (*domain.Cart, error) {
+	ctx := context.Background()
+	var c domain.Cart
+	var discountType *string
+	var discountValue, transactionID *int
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, status, reserve_stock, discount_type, discount_value, transaction_id, created_at, updated_at, expires_at
+		 FROM carts WHERE id = $1`, id).
+		Scan(&c.ID, &c.Status, &c.ReserveStock, &discountType, &discountValue, &transactionID,
+			&c.CreatedAt, &c.UpdatedAt, &c.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("cart %d %w", id, ErrNotFound)
+		}
+		return nil, err
+	}
+	c.Discount = discountFromColumns(discountType, discountValue)
+	if transactionID != nil {
+		c.TransactionID = *transactionID
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT ci.id, ci.cart_id, ci.product_id, p.nama, ci.quantity, ci.unit, ci.modifiers, ci.discount_type, ci.discount_value,
+		        ci.reserved_quantity
+		 FROM cart_items ci
+		 JOIN products p ON p.id = ci.product_id
+		 WHERE ci.cart_id = $1
+		 ORDER BY ci.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	c.Items = []domain.CartItem{}
+	for rows.Next() {
+		var it domain.CartItem
+		if err := rows.Scan(&it.ID, &it.CartID, &it.ProductID, &it.ProductName, &it.Quantity, &it.Unit, &it.Modifiers,
+			&discountType, &discountValue, &it.ReservedQuantity); err != nil {
+			return nil, err
+		}
+		it.Discount = discountFromColumns(discountType, discountValue)
+		c.Items = append(c.Items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// AddItem adds item to an open cart, merging it into the line for the same product, unit and
+// modifiers, which must be sorted. A nil
+// item.Discount keeps the line's existing discount.
+func (r *CartPG) AddItem(cartID int, item domain.CheckoutItem, ttl time.Duration) (*domain.Cart, error) {
+	return r.modify(cartID, ttl, func(ctx context.Context, tx pgx.Tx, reserve bool) error {
+		existing, err := cartLineByProduct(ctx, tx, cartID, item.ProductID, item.Unit, item.Modifiers)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			item.Quantity += existing.Quantity
+			if item.Discount == nil {
+				item.Discount = existing.Discount
+			}
+		}
+		return setCartLine(ctx, tx, cartID, reserve, existing, item)
+	})
+}
+
+// UpdateItem replaces the quantity and discount of a line of an open cart.
+func (r *CartPG) UpdateItem(cartID, itemID int, item domain.CheckoutItem, ttl time.Duration) (*domain.Cart, error) {
+	return r.modify(cartID, ttl, func(ctx context.Context, tx pgx.Tx, reserve bool) error {
+		existing, err := cartLineByID(ctx, tx, cartID, itemID)
+		if err != nil {
+			return err
+		}
+		item.ProductID = existing.ProductID
+		item.Unit = existing.Unit
+		item.Modifiers = existing.Modifiers
+		return setCartLine(ctx, tx, cartID, reserve, existing, item)
+	})
+}
+
+// RemoveItem deletes a line of an open cart and returns its reserved stock.
+func (r *CartPG) RemoveItem(cartID, itemID int, ttl time.Duration) (*domain.Cart, error) {
+	return r.modify(cartID, ttl, func(ctx context.Context, tx pgx.Tx, reserve bool) error {
+		existing, err := cartLineByID(ctx, tx, cartID, itemID)
+		if err != nil {
+			return err
+		}
+		if existing.ReservedQuantity > 0 {
+			if _, err := tx.Exec(ctx, "UPDATE products SET stok = stok + $1 WHERE id = $2",
+				existing.ReservedQuantity, existing.ProductID); err != nil {
+				return mapTxError(err)
+			}
+		}
+		_, err = tx.Exec(ctx, "DELETE FROM cart_items WHERE id = $1", itemID)
+		return err
+	})
+}
+
+// Cancel marks an open cart cancelled and returns its reserved stock.
+func (r *CartPG) Cancel(id int) error {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockOpenCart(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := releaseCartStock(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE carts SET status = 'cancelled', updated_at = now() WHERE id = $1", id); err != nil {
+		return err
+	}
+	return mapTxError(tx.Commit(ctx))
+}
+
+// ExpireStale expires open carts past their expiry and returns their reserved stock. Carts being
+// changed concurrently are skipped and picked up by the next run.
+func (r *CartPG) ExpireStale() (int, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT id FROM carts WHERE status = 'open' AND expires_at <= now()
+		 ORDER BY id FOR UPDATE SKIP LOCKED`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := releaseCartStock(ctx, tx, id); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(ctx, "UPDATE carts SET status = 'expired', updated_at = now() WHERE id = $1", id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, mapTxError(err)
+	}
+	return len(ids), nil
+}
+
+// modify runs change on a locked open cart in one DB transaction, then extends the cart's expiry
+// and returns the updated cart.
+func (r *CartPG) modify(cartID int, ttl time.Duration, change func(ctx context.Context, tx pgx.Tx, reserve bool) error) (*domain.Cart, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	reserve, err := lockOpenCart(ctx, tx, cartID)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(ctx, tx, reserve); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx,
+		"UPDATE carts SET updated_at = now(), expires_at = now() + make_interval(secs => $2) WHERE id = $1",
+		cartID, ttl.Seconds()); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, mapTxError(err)
+	}
+	return r.GetByID(cartID)
+}
+
+// lockOpenCart locks a cart row and reports whether it reserves stock. Returns ErrNotFound if the
+// cart does not exist and ErrCartNotOpen if it is no longer open or has expired.
+func lockOpenCart(ctx context.Context, tx pgx.Tx, id int) (reserve bool, err error) {
+	var status string
+	var expired bool
+	err = tx.QueryRow(ctx,
+		"SELECT status, expires_at <= now(), reserve_stock FROM carts WHERE id = $1 FOR UPDATE", id).
+		Scan(&status, &expired, &reserve)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, fmt.Errorf("cart %d %w", id, ErrNotFound)
+		}
+		return false, mapTxError(err)
+	}
+	if status != domain.CartStatusOpen || expired {
+		return false, ErrCartNotOpen
+	}
+	return reserve, nil
+}
+
+// cartLineByProduct returns the line of a cart for a product in a unit with the given sorted
+// modifiers, or nil if there is none.
+func cartLineByProduct(ctx context.Context, tx pgx.Tx, cartID, productID int, unit string, modifiers []int) (*domain.CartItem, error) {
+	it, err := scanCartLine(tx.QueryRow(ctx,
+		`SELECT id, product_id, quantity, unit, modifiers, discount_type, discount_value, reserved_quantity
+		 FROM cart_items WHERE cart_id = $1 AND product_id = $2 AND unit = $3 AND modifiers = COALESCE($4::int[], '{}')`,
+		cartID, productID, unit, modifiers).Scan)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return it, err
+}
+
+// cartLineByID returns a line of a cart, or an error wrapping ErrNotFound.
+func cartLineByID(ctx context.Context, tx pgx.Tx, cartID, itemID int) (*domain.CartItem, error) {
+	it, err := scanCartLine(tx.QueryRow(ctx,
+		`SELECT id, product_id, quantity, unit, modifiers, discount_type, discount_value, reserved_quantity
+		 FROM cart_items WHERE cart_id = $1 AND id = $2`, cartID, itemID).Scan)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("cart item %d %w", itemID, ErrNotFound)
+	}
+	return it, err
+}
+
+func scanCartLine(scan func(...any) error) (*domain.CartItem, error) {
+	var it domain.CartItem
+	var discountType *string
+	var discountValue *int
+	if err := scan(&it.ID, &it.ProductID, &it.Quantity, &it.Unit, &it.Modifiers, &discountType, &discountValue, &it.ReservedQuantity); err != nil {
+		return nil, err
+	}
+	it.Discount = discountFromColumns(discountType, discountValue)
+	return &it, nil
+}
+
+// setCartLine writes item as a cart line, replacing existing if given. When reserve is set, stock
+// is taken or returned so that the line's reserved quantity matches its new quantity in base units.
+// item.Unit must be empty or one of the product's units. Bundles reserve nothing: their components
+// are checked when the cart is checked out.
+func setCartLine(ctx context.Context, tx pgx.Tx, cartID int, reserve bool, existing *domain.CartItem, item domain.CheckoutItem) error {
+	var reserved domain.Quantity
+	if existing != nil {
+		reserved = existing.ReservedQuantity
+	}
+
+	var stock, factor domain.Quantity
+	var bundle bool
+	query := `SELECT p.stok,
+		COALESCE((SELECT u.factor FROM product_units u WHERE u.product_id = p.id AND u.name = $2), 1),
+		EXISTS (SELECT 1 FROM product_components pc WHERE pc.bundle_id = p.id)
+		FROM products p WHERE p.id = $1 AND p.deleted_at IS NULL`
+	if reserve || reserved > 0 {
+		query += " FOR UPDATE"
+	}
+	if err := tx.QueryRow(ctx, query, item.ProductID, item.Unit).Scan(&stock, &factor, &bundle); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("product id %d %w", item.ProductID, ErrNotFound)
+		}
+		return mapTxError(err)
+	}
+	var target domain.Quantity
+	if reserve && !bundle {
+		target = item.Quantity.Mul(factor)
+	}
+	if delta := target - reserved; delta != 0 {
+		if delta > stock {
+			return &ErrInsufficientStock{Items: []domain.StockShortage{{
+				ProductID: item.ProductID,
+				Requested: delta,
+				Available: stock,
+			}}}
+		}
+		if _, err := tx.Exec(ctx, "UPDATE products SET stok = stok - $1 WHERE id = $2", delta, item.ProductID); err != nil {
+			return mapTxError(err)
+		}
+	}
+
+	discountType, discountValue := discountColumns(item.Discount)
+	if existing != nil {
+		_, err := tx.Exec(ctx,
+			`UPDATE cart_items SET quantity = $2, discount_type = $3, discount_value = $4, reserved_quantity = $5
+			 WHERE id = $1`,
+			existing.ID, item.Quantity, discountType, discountValue, target)
+		return err
+	}
+	_, err := tx.Exec(ctx,
+		`INSERT INTO cart_items (cart_id, product_id, quantity, unit, modifiers, discount_type, discount_value, reserved_quantity)
+		 VALUES ($1, $2, $3, $4, COALESCE($5::int[], '{}'), $6, $7, $8)`,
+		cartID, item.ProductID, item.Quantity, item.Unit, item.Modifiers, discountType, discountValue, target)
+	return err
+}
+
+// releaseCartStock returns all stock reserved by a cart's lines, in product id order.
+func releaseCartStock(ctx context.Context, tx pgx.Tx, cartID int) error {
+	rows, err := tx.Query(ctx,
+		`SELECT product_id, reserved_quantity FROM cart_items
+		 WHERE cart_id = $1 AND reserved_quantity > 0 ORDER BY product_id`, cartID)
+	if err != nil {
+		return mapTxError(err)
+	}
+	reserved := make(map[int]domain.Quantity)
+	var ids []int
+	for rows.Next() {
+		var pid int
+		var qty domain.Quantity
+		if err := rows.Scan(&pid, &qty); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := reserved[pid]; !ok {
+			ids = append(ids, pid)
+		}
+		reserved[pid] += qty
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, pid := range ids {
+		if _, err := tx.Exec(ctx, "UPDATE products SET stok = stok + $1 WHERE id = $2", reserved[pid], pid); err != nil {
+			return mapTxError(err)
+		}
+	}
+	_, err = tx.Exec(ctx, "UPDATE cart_items SET reserved_quantity = 0 WHERE cart_id = $1", cartID)
+	return err
+}
+
+// discountColumns splits d into nullable discount_type and discount_value columns.
+func discountColumns(d *domain.Discount) (*string, *int) {
+	if d == nil {
+		return nil, nil
+	}
+	return &d.Type, &d.Value
+}
+
+// discountFromColumns rebuilds a discount from nullable columns.
+func discountFromColumns(typ *string, value *int) *domain.Discount {
+	if typ == nil || value == nil {
+		return nil
+	}
+	return &domain.Discount{Type: *typ, Value: *value}
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"time"
+
+	"kasir-api/internal/domain"
+)
+
+// CartRepository defines the interface for parked cart data access. Every change to an open cart
+// pushes its expiry to ttl from now; changes to carts that are not open return ErrCartNotOpen.
+type CartRepository interface {
+	Create(c domain.Cart, ttl time.Duration) (*domain.Cart, error)
+	GetByID(id int) (*domain.Cart, error)
+	// AddItem adds item to the cart, merging it into the existing line for the same product.
+	AddItem(cartID int, item domain.CheckoutItem, ttl time.Duration) (*domain.Cart, error)
+	// UpdateItem replaces the quantity and discount of a cart line.
+	UpdateItem(cartID, itemID int, item domain.CheckoutItem, ttl time.Duration) (*domain.Cart, error)
+	RemoveItem(cartID, itemID int, ttl time.Duration) (*domain.Cart, error)
+	// Cancel abandons an open cart and returns its reserved stock.
+	Cancel(id int) error
+	// ExpireStale marks open carts past their expiry as expired, returns their reserved stock,
+	// and reports how many carts were expired.
+	ExpireStale() (int, error)
+}
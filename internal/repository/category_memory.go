@@ -1,21 +1,25 @@
 package repository
 
 import (
+	"fmt"
 	"kasir-api/internal/domain"
 	"sync"
 )
 
 // CategoryMemoryRepo is an in-memory implementation of CategoryRepository.
 type CategoryMemoryRepo struct {
-	mu   sync.RWMutex
-	data []domain.Category
+	mu       sync.RWMutex
+	data     []domain.Category
+	deleted  []domain.Category
+	products *ProductMemoryRepo
 }
 
 // NewCategoryMemoryRepo creates a new in-memory category repository with optional initial data.
-func NewCategoryMemoryRepo(initial []domain.Category) *CategoryMemoryRepo {
+// products holds the products that belong to the categories; it may be nil if there are none.
+func NewCategoryMemoryRepo(initial []domain.Category, products *ProductMemoryRepo) *CategoryMemoryRepo {
 	data := make([]domain.Category, len(initial))
 	copy(data, initial)
-	return &CategoryMemoryRepo{data: data}
+	return &CategoryMemoryRepo{data: data, products: products}
 }
 
 // GetAll returns all categories.
@@ -40,13 +44,13 @@ func (r *CategoryMemoryRepo) GetByID(id int) (*domain.Category, error) {
 	return nil, ErrNotFound
 }
 
-// Create adds a new category. If c.ID is 0, assigns the next ID.
+// Create adds a new category. If c.ID is 0, assigns the next ID, never reusing the ID of a deleted category.
 func (r *CategoryMemoryRepo) Create(c domain.Category) (domain.Category, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if c.ID == 0 {
 		maxID := 0
-		for _, cat := range r.data {
+		for _, cat := range append(r.data[:len(r.data):len(r.data)], r.deleted...) {
 			if cat.ID > maxID {
 				maxID = cat.ID
 			}
@@ -57,10 +61,16 @@ func (r *CategoryMemoryRepo) Create(c domain.Category) (domain.Category, error)
 	return c, nil
 }
 
-// Update updates an existing category by ID.
+// Update updates an existing category by ID. Returns ErrCategoryCycle if c.ParentID is id or one of
+// its subcategories.
 func (r *CategoryMemoryRepo) Update(id int, c domain.Category) (domain.Category, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if c.ParentID != nil {
+		if err := checkParent(r.data, id, *c.ParentID); err != nil {
+			return domain.Category{}, err
+		}
+	}
 	for i := range r.data {
 		if r.data[i].ID == id {
 			c.ID = id
@@ -71,15 +81,133 @@ func (r *CategoryMemoryRepo) Update(id int, c domain.Category) (domain.Category,
 	return domain.Category{}, ErrNotFound
 }
 
-// Delete removes a category by ID.
+// Delete soft-deletes a category by ID unless products or subcategories still belong to it.
 func (r *CategoryMemoryRepo) Delete(id int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	for i := range r.data {
 		if r.data[i].ID == id {
+			if inUse := r.usage(id); inUse.Products > 0 || inUse.Subcategories > 0 {
+				return inUse
+			}
+			r.deleted = append(r.deleted, r.data[i])
 			r.data = append(r.data[:i], r.data[i+1:]...)
 			return nil
 		}
 	}
 	return ErrNotFound
 }
+
+// usage counts the products and subcategories in category id. r.mu must be held.
+func (r *CategoryMemoryRepo) usage(id int) *ErrCategoryInUse {
+	inUse := &ErrCategoryInUse{CategoryID: id}
+	for _, c := range r.data {
+		if c.ParentID != nil && *c.ParentID == id {
+			inUse.Subcategories++
+		}
+	}
+	if r.products != nil {
+		r.products.mu.RLock()
+		defer r.products.mu.RUnlock()
+		for _, p := range r.products.data {
+			if p.Category.ID == id {
+				inUse.Products++
+			}
+		}
+	}
+	return inUse
+}
+
+// ReassignAndDelete moves all products, including deleted ones, from category id to category to
+// and soft-deletes id, unless id still has subcategories.
+func (r *CategoryMemoryRepo) ReassignAndDelete(id, to int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	src, dst := -1, -1
+	for i := range r.data {
+		switch r.data[i].ID {
+		case id:
+			src = i
+		case to:
+			dst = i
+		}
+	}
+	if src < 0 {
+		return ErrNotFound
+	}
+	if dst < 0 {
+		return fmt.Errorf("category %d %w", to, ErrNotFound)
+	}
+	if inUse := r.usage(id); inUse.Subcategories > 0 {
+		return inUse
+	}
+	if r.products != nil {
+		r.products.mu.Lock()
+		for _, list := range [][]domain.Product{r.products.data, r.products.deleted} {
+			for i := range list {
+				if list[i].Category.ID == id {
+					list[i].Category = r.data[dst]
+				}
+			}
+		}
+		r.products.mu.Unlock()
+	}
+	r.deleted = append(r.deleted, r.data[src])
+	r.data = append(r.data[:src], r.data[src+1:]...)
+	return nil
+}
+
+// Restore undeletes a soft-deleted category.
+func (r *CategoryMemoryRepo) Restore(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.deleted {
+		if r.deleted[i].ID == id {
+			r.data = append(r.data, r.deleted[i])
+			r.deleted = append(r.deleted[:i], r.deleted[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Purge permanently removes soft-deleted categories that no product, deleted or not, and no
+// subcategory refers to, leaf-first like CategoryPG.Purge.
+func (r *CategoryMemoryRepo) Purge() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used := make(map[int]bool)
+	if r.products != nil {
+		r.products.mu.RLock()
+		for _, p := range append(r.products.data[:len(r.products.data):len(r.products.data)], r.products.deleted...) {
+			used[p.Category.ID] = true
+		}
+		r.products.mu.RUnlock()
+	}
+	for _, c := range r.data {
+		if c.ParentID != nil {
+			used[*c.ParentID] = true
+		}
+	}
+	n := 0
+	for {
+		parents := make(map[int]bool)
+		for _, c := range r.deleted {
+			if c.ParentID != nil {
+				parents[*c.ParentID] = true
+			}
+		}
+		kept := r.deleted[:0]
+		for _, c := range r.deleted {
+			if used[c.ID] || parents[c.ID] {
+				kept = append(kept, c)
+			}
+		}
+		removed := len(r.deleted) - len(kept)
+		r.deleted = kept
+		if removed == 0 {
+			return n, nil
+		}
+		n += removed
+	}
+}
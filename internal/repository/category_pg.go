@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 
 	"kasir-api/internal/domain"
 
@@ -23,7 +25,7 @@ func NewCategoryPG(pool *pgxpool.Pool) *CategoryPG {
 // GetAll returns all categories.
 func (r *CategoryPG) GetAll() ([]domain.Category, error) {
 	rows, err := r.pool.Query(context.Background(),
-		"SELECT id, nama FROM categories ORDER BY id")
+		"SELECT id, nama, parent_id FROM categories WHERE deleted_at IS NULL ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +34,7 @@ func (r *CategoryPG) GetAll() ([]domain.Category, error) {
 	var out []domain.Category
 	for rows.Next() {
 		var c domain.Category
-		if err := rows.Scan(&c.ID, &c.Nama); err != nil {
+		if err := rows.Scan(&c.ID, &c.Nama, &c.ParentID); err != nil {
 			return nil, err
 		}
 		out = append(out, c)
@@ -44,7 +46,7 @@ func (r *CategoryPG) GetAll() ([]domain.Category, error) {
 func (r *CategoryPG) GetByID(id int) (*domain.Category, error) {
 	var c domain.Category
 	err := r.pool.QueryRow(context.Background(),
-		"SELECT id, nama FROM categories WHERE id = $1", id).Scan(&c.ID, &c.Nama)
+		"SELECT id, nama, parent_id FROM categories WHERE id = $1 AND deleted_at IS NULL", id).Scan(&c.ID, &c.Nama, &c.ParentID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -58,30 +60,182 @@ func (r *CategoryPG) GetByID(id int) (*domain.Category, error) {
 func (r *CategoryPG) Create(c domain.Category) (domain.Category, error) {
 	var out domain.Category
 	err := r.pool.QueryRow(context.Background(),
-		"INSERT INTO categories (nama) VALUES ($1) RETURNING id, nama", c.Nama).Scan(&out.ID, &out.Nama)
+		"INSERT INTO categories (nama, parent_id) VALUES ($1, $2) RETURNING id, nama, parent_id",
+		c.Nama, c.ParentID).Scan(&out.ID, &out.Nama, &out.ParentID)
 	if err != nil {
 		return domain.Category{}, err
 	}
 	return out, nil
 }
 
-// Update updates a category by ID and returns it, or ErrNotFound.
+// Update updates a category by ID and returns it, or ErrNotFound. If c.ParentID is set, every
+// category row is locked in ID order before the new parent is checked, so a concurrent move cannot
+// change the tree between the check and the update. Returns ErrCategoryCycle if the parent is id or
+// one of its subcategories, and an error wrapping ErrNotFound if it no longer exists.
 func (r *CategoryPG) Update(id int, c domain.Category) (domain.Category, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.Category{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if c.ParentID != nil {
+		// FOR NO KEY UPDATE does not block the key-share locks taken by products referencing a category.
+		rows, err := tx.Query(ctx, "SELECT id, parent_id FROM categories WHERE deleted_at IS NULL ORDER BY id FOR NO KEY UPDATE")
+		if err != nil {
+			return domain.Category{}, mapTxError(err)
+		}
+		var cats []domain.Category
+		for rows.Next() {
+			var cat domain.Category
+			if err := rows.Scan(&cat.ID, &cat.ParentID); err != nil {
+				rows.Close()
+				return domain.Category{}, err
+			}
+			cats = append(cats, cat)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return domain.Category{}, mapTxError(err)
+		}
+		if err := checkParent(cats, id, *c.ParentID); err != nil {
+			return domain.Category{}, err
+		}
+	}
+
 	var out domain.Category
-	err := r.pool.QueryRow(context.Background(),
-		"UPDATE categories SET nama = $2 WHERE id = $1 RETURNING id, nama", id, c.Nama).Scan(&out.ID, &out.Nama)
+	err = tx.QueryRow(ctx,
+		"UPDATE categories SET nama = $2, parent_id = $3 WHERE id = $1 AND deleted_at IS NULL RETURNING id, nama, parent_id",
+		id, c.Nama, c.ParentID).Scan(&out.ID, &out.Nama, &out.ParentID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return domain.Category{}, ErrNotFound
 		}
-		return domain.Category{}, err
+		return domain.Category{}, mapTxError(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Category{}, mapTxError(err)
 	}
 	return out, nil
 }
 
-// Delete removes a category by ID. Returns ErrNotFound if no rows affected.
+// checkParent checks that category parent exists in cats and that placing id under it would not
+// make id its own ancestor.
+func checkParent(cats []domain.Category, id, parent int) error {
+	if !slices.ContainsFunc(cats, func(c domain.Category) bool { return c.ID == parent }) {
+		return fmt.Errorf("parent category id %d %w", parent, ErrNotFound)
+	}
+	if slices.Contains(domain.CategoryAncestors(cats, parent), id) {
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+// Delete soft-deletes a category by ID. Returns ErrNotFound if it does not exist or is already
+// deleted, and *ErrCategoryInUse if products or subcategories that are not deleted still belong to
+// it. The category row is locked first, so no product or subcategory can be added to it between
+// the check and the delete.
 func (r *CategoryPG) Delete(id int) error {
-	cmd, err := r.pool.Exec(context.Background(), "DELETE FROM categories WHERE id = $1", id)
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked int
+	err = tx.QueryRow(ctx, "SELECT id FROM categories WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	inUse, err := categoryUsage(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if inUse.Products > 0 || inUse.Subcategories > 0 {
+		return inUse
+	}
+	if _, err := tx.Exec(ctx, "UPDATE categories SET deleted_at = now() WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// categoryUsage counts the products and subcategories that are not deleted in category id.
+func categoryUsage(ctx context.Context, tx pgx.Tx, id int) (*ErrCategoryInUse, error) {
+	inUse := &ErrCategoryInUse{CategoryID: id}
+	err := tx.QueryRow(ctx,
+		`SELECT (SELECT COUNT(*) FROM products WHERE category_id = $1 AND deleted_at IS NULL),
+		        (SELECT COUNT(*) FROM categories WHERE parent_id = $1 AND deleted_at IS NULL)`, id).
+		Scan(&inUse.Products, &inUse.Subcategories)
+	if err != nil {
+		return nil, err
+	}
+	return inUse, nil
+}
+
+// ReassignAndDelete moves all products, including deleted ones, from category id to category to
+// and soft-deletes id in one DB transaction. Returns ErrNotFound if either category does not exist
+// or is deleted, and *ErrCategoryInUse if id still has subcategories that are not deleted.
+func (r *CategoryPG) ReassignAndDelete(id, to int) error {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock both categories in id order so they cannot be deleted underneath us.
+	rows, err := tx.Query(ctx,
+		"SELECT id FROM categories WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id FOR UPDATE",
+		[]int{id, to})
+	if err != nil {
+		return err
+	}
+	found := make(map[int]bool, 2)
+	for rows.Next() {
+		var cid int
+		if err := rows.Scan(&cid); err != nil {
+			rows.Close()
+			return err
+		}
+		found[cid] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !found[id] {
+		return ErrNotFound
+	}
+	if !found[to] {
+		return fmt.Errorf("category %d %w", to, ErrNotFound)
+	}
+	inUse, err := categoryUsage(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if inUse.Subcategories > 0 {
+		return inUse
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE products SET category_id = $2 WHERE category_id = $1", id, to); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE categories SET deleted_at = now() WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Restore undeletes a soft-deleted category. Returns ErrNotFound if there is no deleted category with that ID.
+func (r *CategoryPG) Restore(id int) error {
+	cmd, err := r.pool.Exec(context.Background(),
+		"UPDATE categories SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
 	if err != nil {
 		return err
 	}
@@ -90,3 +244,24 @@ func (r *CategoryPG) Delete(id int) error {
 	}
 	return nil
 }
+
+// Purge permanently removes soft-deleted categories that no product, deleted or not, and no
+// subcategory refers to and returns how many were removed. It purges leaf-first, so a deleted
+// category goes in the same call as the deleted subcategories under it.
+func (r *CategoryPG) Purge() (int, error) {
+	total := 0
+	for {
+		cmd, err := r.pool.Exec(context.Background(),
+			`DELETE FROM categories c
+			 WHERE c.deleted_at IS NOT NULL
+			   AND NOT EXISTS (SELECT 1 FROM products p WHERE p.category_id = c.id)
+			   AND NOT EXISTS (SELECT 1 FROM categories ch WHERE ch.parent_id = c.id)`)
+		if err != nil {
+			return total, err
+		}
+		if cmd.RowsAffected() == 0 {
+			return total, nil
+		}
+		total += int(cmd.RowsAffected())
+	}
+}
@@ -7,6 +7,17 @@ type CategoryRepository interface {
 	GetAll() ([]domain.Category, error)
 	GetByID(id int) (*domain.Category, error)
 	Create(c domain.Category) (domain.Category, error)
+	// Update returns ErrCategoryCycle if c.ParentID is id or one of its subcategories, checked in
+	// the same transaction as the update.
 	Update(id int, c domain.Category) (domain.Category, error)
+	// Delete soft-deletes a category; deleted categories are hidden from every other method.
+	// It returns *ErrCategoryInUse instead if products or subcategories that are not deleted
+	// still belong to the category, checked in the same transaction.
 	Delete(id int) error
+	// ReassignAndDelete moves every product of category id to category to and soft-deletes id,
+	// all in one transaction. It returns *ErrCategoryInUse if id still has subcategories.
+	ReassignAndDelete(id, to int) error
+	Restore(id int) error
+	// Purge permanently removes deleted categories that no product refers to.
+	Purge() (int, error)
 }
@@ -1,6 +1,56 @@
 package repository
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"kasir-api/internal/domain"
+)
 
 // ErrNotFound is returned when an entity is not found.
 var ErrNotFound = errors.New("not found")
+
+// ErrSerialization is returned when a write lost a race with a concurrent transaction
+// (serialization failure or deadlock). The operation did not take effect and can be retried.
+var ErrSerialization = errors.New("concurrent update, please retry")
+
+// ErrTransactionVoided is returned when voiding or refunding a transaction that was already voided.
+var ErrTransactionVoided = errors.New("transaction already voided")
+
+// ErrInvalidRefund is returned (wrapped with details) when refund lines do not match what is refundable.
+var ErrInvalidRefund = errors.New("invalid refund")
+
+// ErrDuplicate is returned (wrapped with the field) when a unique value such as a SKU or barcode is already taken.
+var ErrDuplicate = errors.New("already in use")
+
+// ErrCartNotOpen is returned when changing or checking out a cart that expired, was cancelled or was already checked out.
+var ErrCartNotOpen = errors.New("cart is not open")
+
+// ErrCategoryCycle is returned when a category would be placed under itself or one of its subcategories.
+var ErrCategoryCycle = errors.New("category cannot be placed under itself or its subcategories")
+
+// ErrCategoryInUse is returned when deleting a category that products or subcategories still belong to.
+type ErrCategoryInUse struct {
+	CategoryID    int
+	Products      int
+	Subcategories int
+}
+
+func (e *ErrCategoryInUse) Error() string {
+	return fmt.Sprintf("category id %d still has %d product(s) and %d subcategory(ies)", e.CategoryID, e.Products, e.Subcategories)
+}
+
+// ErrInsufficientStock is returned when a checkout asks for more units than are in stock.
+// It lists every short item so the caller can report them all at once.
+type ErrInsufficientStock struct {
+	Items []domain.StockShortage
+}
+
+func (e *ErrInsufficientStock) Error() string {
+	parts := make([]string, 0, len(e.Items))
+	for _, it := range e.Items {
+		parts = append(parts, fmt.Sprintf("product id %d (requested %s, available %s)", it.ProductID, it.Requested, it.Available))
+	}
+	return "insufficient stock: " + strings.Join(parts, ", ")
+}
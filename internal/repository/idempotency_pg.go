@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"kasir-api/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IdempotencyPG is a PostgreSQL implementation of IdempotencyRepository.
+type IdempotencyPG struct {
+	pool *pgxpool.Pool
+}
+
+// NewIdempotencyPG creates a new PostgreSQL idempotency key repository.
+func NewIdempotencyPG(pool *pgxpool.Pool) *IdempotencyPG {
+	return &IdempotencyPG{pool: pool}
+}
+
+// Reserve purges expired keys, then inserts key unless another request already holds it.
+func (r *IdempotencyPG) Reserve(key, requestHash string, ttl time.Duration) (*domain.IdempotencyRecord, bool, error) {
+	ctx := context.Background()
+	if _, err := r.pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= now()"); err != nil {
+		return nil, false, err
+	}
+
+	cmd, err := r.pool.Exec(ctx,
+		`INSERT INTO idempotency_keys (key, request_hash, expires_at)
+		 VALUES ($1, $2, now() + make_interval(secs => $3))
+		 ON CONFLICT (key) DO NOTHING`,
+		key, requestHash, ttl.Seconds())
+	if err != nil {
+		return nil, false, err
+	}
+	if cmd.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+
+	var rec domain.IdempotencyRecord
+	var response []byte
+	err = r.pool.QueryRow(ctx,
+		"SELECT key, request_hash, response, created_at, expires_at FROM idempotency_keys WHERE key = $1", key).
+		Scan(&rec.Key, &rec.RequestHash, &response, &rec.CreatedAt, &rec.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Released between our insert and select; let the caller retry the reservation.
+			return nil, false, ErrSerialization
+		}
+		return nil, false, err
+	}
+	if response != nil {
+		var tx domain.Transaction
+		if err := json.Unmarshal(response, &tx); err != nil {
+			return nil, false, err
+		}
+		rec.Transaction = &tx
+	}
+	return &rec, false, nil
+}
+
+// Release deletes a key that has no stored response.
+func (r *IdempotencyPG) Release(key string) error {
+	_, err := r.pool.Exec(context.Background(),
+		"DELETE FROM idempotency_keys WHERE key = $1 AND response IS NULL", key)
+	return err
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"time"
+
+	"kasir-api/internal/domain"
+)
+
+// IdempotencyRepository defines the interface for storing checkout idempotency keys. The
+// transaction made for a reserved key is stored by TransactionRepository.CreateTransaction.
+type IdempotencyRepository interface {
+	// Reserve stores key with requestHash if it is not already held by an unexpired record.
+	// Returns (nil, true, nil) when the key was reserved, or the existing record and false.
+	Reserve(key, requestHash string, ttl time.Duration) (*domain.IdempotencyRecord, bool, error)
+	// Release drops a reserved key whose request failed, so the client can retry with it.
+	Release(key string) error
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"kasir-api/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ModifierPG is a PostgreSQL implementation of ModifierRepository.
+type ModifierPG struct {
+	pool *pgxpool.Pool
+}
+
+// NewModifierPG creates a new PostgreSQL modifier repository.
+func NewModifierPG(pool *pgxpool.Pool) *ModifierPG {
+	return &ModifierPG{pool: pool}
+}
+
+// modifierGroupColumns is the select list read by scanModifierGroup; queries alias modifier_groups as g.
+const modifierGroupColumns = `g.id, g.nama, g.min_select, g.max_select,
+	COALESCE((SELECT json_agg(json_build_object('id', m.id, 'nama', m.nama, 'harga', m.harga) ORDER BY m.id)
+		FROM modifiers m WHERE m.group_id = g.id), '[]'),
+	g.product_ids, g.category_ids`
+
+func scanModifierGroup(scan func(...any) error) (domain.ModifierGroup, error) {
+	var g domain.ModifierGroup
+	err := scan(&g.ID, &g.Nama, &g.MinSelect, &g.MaxSelect, &g.Options, &g.ProductIDs, &g.CategoryIDs)
+	return g, err
+}
+
+func (r *ModifierPG) query(where string, args ...any) ([]domain.ModifierGroup, error) {
+	rows, err := r.pool.Query(context.Background(),
+		`SELECT `+modifierGroupColumns+` FROM modifier_groups g `+where+` ORDER BY g.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.ModifierGroup
+	for rows.Next() {
+		g, err := scanModifierGroup(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// GetAll returns all modifier groups with their options.
+func (r *ModifierPG) GetAll() ([]domain.ModifierGroup, error) {
+	return r.query("")
+}
+
+// GetByID returns a modifier group by ID or ErrNotFound.
+func (r *ModifierPG) GetByID(id int) (*domain.ModifierGroup, error) {
+	row := r.pool.QueryRow(context.Background(),
+		`SELECT `+modifierGroupColumns+` FROM modifier_groups g WHERE g.id = $1`, id)
+	g, err := scanModifierGroup(row.Scan)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &g, nil
+}
+
+// GetApplicable returns the groups attached to any of productIDs or categoryIDs, ordered by ID.
+func (r *ModifierPG) GetApplicable(productIDs, categoryIDs []int) ([]domain.ModifierGroup, error) {
+	return r.query("WHERE g.product_ids && $1::int[] OR g.category_ids && $2::int[]", productIDs, categoryIDs)
+}
+
+// Create inserts a group with its options and returns it with the generated IDs.
+func (r *ModifierPG) Create(g domain.ModifierGroup) (domain.ModifierGroup, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO modifier_groups (nama, min_select, max_select, product_ids, category_ids)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		g.Nama, g.MinSelect, g.MaxSelect, g.ProductIDs, g.CategoryIDs).Scan(&id)
+	if err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	if err := setModifiers(ctx, tx, id, g.Options); err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	created, err := r.GetByID(id)
+	if err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	return *created, nil
+}
+
+// Update replaces a group and its options and returns it, or ErrNotFound. An option whose ID does
+// not belong to the group wraps ErrNotFound as well.
+func (r *ModifierPG) Update(id int, g domain.ModifierGroup) (domain.ModifierGroup, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx,
+		`UPDATE modifier_groups SET nama = $2, min_select = $3, max_select = $4, product_ids = $5, category_ids = $6
+		 WHERE id = $1`,
+		id, g.Nama, g.MinSelect, g.MaxSelect, g.ProductIDs, g.CategoryIDs)
+	if err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.ModifierGroup{}, ErrNotFound
+	}
+	keep := []int{}
+	for _, m := range g.Options {
+		if m.ID != 0 {
+			keep = append(keep, m.ID)
+		}
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM modifiers WHERE group_id = $1 AND NOT (id = ANY($2))", id, keep); err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	if err := setModifiers(ctx, tx, id, g.Options); err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	updated, err := r.GetByID(id)
+	if err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	return *updated, nil
+}
+
+// Delete removes a group and its options. Transactions keep the modifiers they were sold with.
+// Returns ErrNotFound if it does not exist.
+func (r *ModifierPG) Delete(id int) error {
+	cmd, err := r.pool.Exec(context.Background(), "DELETE FROM modifier_groups WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// setModifiers updates the options of group id that have an ID and inserts the others.
+func setModifiers(ctx context.Context, tx pgx.Tx, id int, options []domain.Modifier) error {
+	for _, m := range options {
+		if m.ID == 0 {
+			if _, err := tx.Exec(ctx,
+				"INSERT INTO modifiers (group_id, nama, harga) VALUES ($1, $2, $3)", id, m.Nama, m.Harga); err != nil {
+				return err
+			}
+			continue
+		}
+		cmd, err := tx.Exec(ctx,
+			"UPDATE modifiers SET nama = $3, harga = $4 WHERE id = $2 AND group_id = $1", id, m.ID, m.Nama, m.Harga)
+		if err != nil {
+			return err
+		}
+		if cmd.RowsAffected() == 0 {
+			return fmt.Errorf("modifier id %d %w", m.ID, ErrNotFound)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package repository
+
+import "kasir-api/internal/domain"
+
+// ModifierRepository defines the interface for modifier group data access.
+type ModifierRepository interface {
+	GetAll() ([]domain.ModifierGroup, error)
+	GetByID(id int) (*domain.ModifierGroup, error)
+	// GetApplicable returns the groups attached to any of productIDs or categoryIDs.
+	GetApplicable(productIDs, categoryIDs []int) ([]domain.ModifierGroup, error)
+	Create(g domain.ModifierGroup) (domain.ModifierGroup, error)
+	// Update replaces a group. Options with the ID of one of its options update that option, options
+	// without an ID are added, and options that are left out are removed.
+	Update(id int, g domain.ModifierGroup) (domain.ModifierGroup, error)
+	Delete(id int) error
+}
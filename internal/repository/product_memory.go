@@ -1,15 +1,18 @@
 package repository
 
 import (
+	"fmt"
 	"kasir-api/internal/domain"
+	"slices"
 	"strings"
 	"sync"
 )
 
 // ProductMemoryRepo is an in-memory implementation of ProductRepository.
 type ProductMemoryRepo struct {
-	mu   sync.RWMutex
-	data []domain.Product
+	mu      sync.RWMutex
+	data    []domain.Product
+	deleted []domain.Product
 }
 
 // NewProductMemoryRepo creates a new in-memory product repository with optional initial data.
@@ -19,18 +22,27 @@ func NewProductMemoryRepo(initial []domain.Product) *ProductMemoryRepo {
 	return &ProductMemoryRepo{data: data}
 }
 
-// GetAll returns all products. If name is non-empty, filters by product name (substring, case-insensitive).
-func (r *ProductMemoryRepo) GetAll(name string) ([]domain.Product, error) {
+// GetAll returns the products matching f. Name is matched as a case-insensitive substring.
+func (r *ProductMemoryRepo) GetAll(f domain.ProductFilter) ([]domain.Product, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	if name == "" {
-		out := make([]domain.Product, len(r.data))
-		copy(out, r.data)
-		return out, nil
-	}
-	lower := strings.ToLower(name)
-	var out []domain.Product
+	lower := strings.ToLower(f.Name)
+	out := []domain.Product{}
 	for _, p := range r.data {
+		if !f.IncludeInactive && !p.Active {
+			continue
+		}
+		if len(f.CategoryIDs) > 0 && !slices.Contains(f.CategoryIDs, p.Category.ID) {
+			continue
+		}
+		if f.ParentID != 0 && (p.ParentID == nil || *p.ParentID != f.ParentID) {
+			continue
+		}
+		if f.ComponentID != 0 && !slices.ContainsFunc(p.Components, func(c domain.BundleComponent) bool {
+			return c.ProductID == f.ComponentID
+		}) {
+			continue
+		}
 		if strings.Contains(strings.ToLower(p.Nama), lower) {
 			out = append(out, p)
 		}
@@ -51,13 +63,68 @@ func (r *ProductMemoryRepo) GetByID(id int) (*domain.Product, error) {
 	return nil, ErrNotFound
 }
 
-// Create adds a new product. Category must be resolved by caller. If p.ID is 0, assigns the next ID.
+// GetByIDs returns the products with the given IDs in stored order. Unknown IDs are skipped.
+func (r *ProductMemoryRepo) GetByIDs(ids []int) ([]domain.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	want := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	var out []domain.Product
+	for _, p := range r.data {
+		if want[p.ID] {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// GetByBarcodes returns the products carrying the given barcodes, keyed by barcode.
+func (r *ProductMemoryRepo) GetByBarcodes(codes []string) (map[string]domain.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]domain.Product, len(codes))
+	for _, p := range r.data {
+		for _, code := range p.Barcodes {
+			if slices.Contains(codes, code) {
+				out[code] = p
+			}
+		}
+	}
+	return out, nil
+}
+
+// checkUnique returns an error wrapping ErrDuplicate if a product other than id, deleted or not,
+// already has p's SKU or one of its barcodes. Callers hold r.mu.
+func (r *ProductMemoryRepo) checkUnique(id int, p domain.Product) error {
+	for _, other := range append(r.data[:len(r.data):len(r.data)], r.deleted...) {
+		if other.ID == id {
+			continue
+		}
+		if p.SKU != "" && other.SKU == p.SKU {
+			return fmt.Errorf("sku %w", ErrDuplicate)
+		}
+		for _, code := range p.Barcodes {
+			if slices.Contains(other.Barcodes, code) {
+				return fmt.Errorf("barcode %w", ErrDuplicate)
+			}
+		}
+	}
+	return nil
+}
+
+// Create adds a new product. Category must be resolved by caller. If p.ID is 0, assigns the next ID,
+// never reusing the ID of a deleted product.
 func (r *ProductMemoryRepo) Create(p domain.Product) (domain.Product, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if err := r.checkUnique(p.ID, p); err != nil {
+		return domain.Product{}, err
+	}
 	if p.ID == 0 {
 		maxID := 0
-		for _, prod := range r.data {
+		for _, prod := range append(r.data[:len(r.data):len(r.data)], r.deleted...) {
 			if prod.ID > maxID {
 				maxID = prod.ID
 			}
@@ -68,14 +135,21 @@ func (r *ProductMemoryRepo) Create(p domain.Product) (domain.Product, error) {
 	return p, nil
 }
 
-// Update updates an existing product by ID. ID and Category are preserved.
-func (r *ProductMemoryRepo) Update(id int, p domain.Product) (domain.Product, error) {
+// Update updates an existing product by ID. ID and Category are preserved, and so is Stok if stock is nil.
+func (r *ProductMemoryRepo) Update(id int, p domain.Product, stock *domain.Quantity) (domain.Product, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	for i := range r.data {
 		if r.data[i].ID == id {
+			if err := r.checkUnique(id, p); err != nil {
+				return domain.Product{}, err
+			}
 			p.ID = id
 			p.Category = r.data[i].Category
+			p.Stok = r.data[i].Stok
+			if stock != nil {
+				p.Stok = *stock
+			}
 			r.data[i] = p
 			return p, nil
 		}
@@ -83,15 +157,44 @@ func (r *ProductMemoryRepo) Update(id int, p domain.Product) (domain.Product, er
 	return domain.Product{}, ErrNotFound
 }
 
-// Delete removes a product by ID.
+// Delete soft-deletes a product by ID together with its variants.
 func (r *ProductMemoryRepo) Delete(id int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	for i := range r.data {
-		if r.data[i].ID == id {
-			r.data = append(r.data[:i], r.data[i+1:]...)
+	if !slices.ContainsFunc(r.data, func(p domain.Product) bool { return p.ID == id }) {
+		return ErrNotFound
+	}
+	kept := r.data[:0]
+	for _, p := range r.data {
+		if p.ID == id || (p.ParentID != nil && *p.ParentID == id) {
+			r.deleted = append(r.deleted, p)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	r.data = kept
+	return nil
+}
+
+// Restore undeletes a soft-deleted product.
+func (r *ProductMemoryRepo) Restore(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.deleted {
+		if r.deleted[i].ID == id {
+			r.data = append(r.data, r.deleted[i])
+			r.deleted = append(r.deleted[:i], r.deleted[i+1:]...)
 			return nil
 		}
 	}
 	return ErrNotFound
 }
+
+// Purge permanently removes all soft-deleted products; the memory store keeps no transactions.
+func (r *ProductMemoryRepo) Purge() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.deleted)
+	r.deleted = nil
+	return n, nil
+}
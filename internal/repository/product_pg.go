@@ -2,11 +2,14 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"kasir-api/internal/domain"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -20,11 +23,26 @@ func NewProductPG(pool *pgxpool.Pool) *ProductPG {
 	return &ProductPG{pool: pool}
 }
 
+// productColumns is the select list read by scanProduct; queries alias products as p and categories as c.
+// The stock of a bundle is the number of whole bundles its components make up.
+const productColumns = `p.id, p.nama, COALESCE(p.sku, ''),
+	COALESCE((SELECT array_agg(b.barcode ORDER BY b.barcode) FROM product_barcodes b WHERE b.product_id = p.id), '{}'),
+	p.harga, p.harga_pokok,
+	COALESCE((SELECT MIN(FLOOR(cp.stok / pc.quantity)) FROM product_components pc
+		JOIN products cp ON cp.id = pc.component_id WHERE pc.bundle_id = p.id), p.stok),
+	p.unit,
+	COALESCE((SELECT json_agg(json_build_object('name', u.name, 'factor', u.factor, 'harga', u.harga) ORDER BY u.factor, u.name)
+		FROM product_units u WHERE u.product_id = p.id), '[]'),
+	p.active, c.id, c.nama, p.parent_id, p.variant_attributes, p.attributes,
+	COALESCE((SELECT json_agg(json_build_object('product_id', pc.component_id, 'product_name', cp.nama, 'quantity', pc.quantity) ORDER BY pc.component_id)
+		FROM product_components pc JOIN products cp ON cp.id = pc.component_id WHERE pc.bundle_id = p.id), '[]')`
+
 func scanProduct(scan func(...any) error) (domain.Product, error) {
 	var p domain.Product
 	var catID int
 	var catNama string
-	err := scan(&p.ID, &p.Nama, &p.Harga, &p.Stok, &catID, &catNama)
+	err := scan(&p.ID, &p.Nama, &p.SKU, &p.Barcodes, &p.Harga, &p.HargaPokok, &p.Stok, &p.Unit, &p.Units, &p.Active, &catID, &catNama,
+		&p.ParentID, &p.VariantAttributes, &p.Attributes, &p.Components)
 	if err != nil {
 		return domain.Product{}, err
 	}
@@ -32,15 +50,31 @@ func scanProduct(scan func(...any) error) (domain.Product, error) {
 	return p, nil
 }
 
-// GetAll returns all products with their category. If name is non-empty, filters by product name (ILIKE).
-func (r *ProductPG) GetAll(name string) ([]domain.Product, error) {
-	query := `SELECT p.id, p.nama, p.harga, p.stok, c.id, c.nama
+// GetAll returns the products matching f with their category. Name is matched with ILIKE.
+func (r *ProductPG) GetAll(f domain.ProductFilter) ([]domain.Product, error) {
+	query := `SELECT ` + productColumns + `
 		FROM products p
-		JOIN categories c ON p.category_id = c.id`
+		JOIN categories c ON p.category_id = c.id
+		WHERE p.deleted_at IS NULL`
 	args := []any{}
-	if name != "" {
-		query += ` WHERE p.nama ILIKE '%' || $1 || '%'`
-		args = append(args, name)
+	if f.Name != "" {
+		args = append(args, f.Name)
+		query += fmt.Sprintf(` AND p.nama ILIKE '%%' || $%d || '%%'`, len(args))
+	}
+	if !f.IncludeInactive {
+		query += ` AND p.active`
+	}
+	if len(f.CategoryIDs) > 0 {
+		args = append(args, f.CategoryIDs)
+		query += fmt.Sprintf(` AND p.category_id = ANY($%d)`, len(args))
+	}
+	if f.ParentID != 0 {
+		args = append(args, f.ParentID)
+		query += fmt.Sprintf(` AND p.parent_id = $%d`, len(args))
+	}
+	if f.ComponentID != 0 {
+		args = append(args, f.ComponentID)
+		query += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM product_components pc WHERE pc.bundle_id = p.id AND pc.component_id = $%d)`, len(args))
 	}
 	query += ` ORDER BY p.id`
 
@@ -64,10 +98,10 @@ func (r *ProductPG) GetAll(name string) ([]domain.Product, error) {
 // GetByID returns a product by ID with its category, or ErrNotFound.
 func (r *ProductPG) GetByID(id int) (*domain.Product, error) {
 	row := r.pool.QueryRow(context.Background(),
-		`SELECT p.id, p.nama, p.harga, p.stok, c.id, c.nama
+		`SELECT `+productColumns+`
 		 FROM products p
 		 JOIN categories c ON p.category_id = c.id
-		 WHERE p.id = $1`, id)
+		 WHERE p.id = $1 AND p.deleted_at IS NULL`, id)
 	p, err := scanProduct(row.Scan)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -78,13 +112,91 @@ func (r *ProductPG) GetByID(id int) (*domain.Product, error) {
 	return &p, nil
 }
 
-// Create inserts a product and returns it with the generated ID and category.
+// GetByIDs returns the products with the given IDs, ordered by ID. Unknown IDs are skipped.
+func (r *ProductPG) GetByIDs(ids []int) ([]domain.Product, error) {
+	rows, err := r.pool.Query(context.Background(),
+		`SELECT `+productColumns+`
+		 FROM products p
+		 JOIN categories c ON p.category_id = c.id
+		 WHERE p.id = ANY($1) AND p.deleted_at IS NULL
+		 ORDER BY p.id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Product
+	for rows.Next() {
+		p, err := scanProduct(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// GetByBarcodes returns the products carrying the given barcodes, keyed by barcode. It is a single
+// lookup on the barcode primary key, which keeps scanning fast.
+func (r *ProductPG) GetByBarcodes(codes []string) (map[string]domain.Product, error) {
+	rows, err := r.pool.Query(context.Background(),
+		`SELECT lb.barcode, `+productColumns+`
+		 FROM product_barcodes lb
+		 JOIN products p ON p.id = lb.product_id
+		 JOIN categories c ON p.category_id = c.id
+		 WHERE lb.barcode = ANY($1) AND p.deleted_at IS NULL`, codes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]domain.Product, len(codes))
+	for rows.Next() {
+		var code string
+		p, err := scanProduct(func(dest ...any) error {
+			return rows.Scan(append([]any{&code}, dest...)...)
+		})
+		if err != nil {
+			return nil, err
+		}
+		out[code] = p
+	}
+	return out, rows.Err()
+}
+
+// Create inserts a product with its barcodes, units and components and returns it with the generated ID and category.
 func (r *ProductPG) Create(p domain.Product) (domain.Product, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	attrs, err := attributesJSON(p.Attributes)
+	if err != nil {
+		return domain.Product{}, err
+	}
 	var id int
-	err := r.pool.QueryRow(context.Background(),
-		`INSERT INTO products (nama, harga, stok, category_id) VALUES ($1, $2, $3, $4) RETURNING id`,
-		p.Nama, p.Harga, p.Stok, p.Category.ID).Scan(&id)
+	err = tx.QueryRow(ctx,
+		`INSERT INTO products (nama, sku, harga, harga_pokok, stok, unit, active, category_id,
+		     parent_id, variant_attributes, attributes)
+		 VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11::jsonb) RETURNING id`,
+		p.Nama, p.SKU, p.Harga, p.HargaPokok, p.Stok, p.Unit, p.Active, p.Category.ID,
+		p.ParentID, variantAttributes(p.VariantAttributes), attrs).Scan(&id)
 	if err != nil {
+		return domain.Product{}, mapUniqueError(err)
+	}
+	if err := setBarcodes(ctx, tx, id, p.Barcodes); err != nil {
+		return domain.Product{}, err
+	}
+	if err := setUnits(ctx, tx, id, p.Units); err != nil {
+		return domain.Product{}, err
+	}
+	if err := setComponents(ctx, tx, id, p.Components); err != nil {
+		return domain.Product{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
 		return domain.Product{}, err
 	}
 	created, err := r.GetByID(id)
@@ -94,17 +206,57 @@ func (r *ProductPG) Create(p domain.Product) (domain.Product, error) {
 	return *created, nil
 }
 
-// Update updates a product by ID and returns the full product, or ErrNotFound.
-func (r *ProductPG) Update(id int, p domain.Product) (domain.Product, error) {
-	cmd, err := r.pool.Exec(context.Background(),
-		`UPDATE products SET nama = $2, harga = $3, stok = $4, category_id = $5 WHERE id = $1`,
-		id, p.Nama, p.Harga, p.Stok, p.Category.ID)
+// Update updates a product by ID, replacing its barcodes, units and components, and returns the full product, or
+// ErrNotFound. Variants follow their parent into its new category. Stock is only written if stock is
+// non-nil, so checkouts committed since the product was read are not undone.
+func (r *ProductPG) Update(id int, p domain.Product, stock *domain.Quantity) (domain.Product, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return domain.Product{}, err
 	}
+	defer tx.Rollback(ctx)
+
+	attrs, err := attributesJSON(p.Attributes)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	cmd, err := tx.Exec(ctx,
+		`UPDATE products SET nama = $2, sku = NULLIF($3, ''), harga = $4, harga_pokok = $5, stok = COALESCE($6, stok), unit = $7, active = $8, category_id = $9,
+		     parent_id = $10, variant_attributes = $11, attributes = $12::jsonb
+		 WHERE id = $1 AND deleted_at IS NULL`,
+		id, p.Nama, p.SKU, p.Harga, p.HargaPokok, stock, p.Unit, p.Active, p.Category.ID,
+		p.ParentID, variantAttributes(p.VariantAttributes), attrs)
+	if err != nil {
+		return domain.Product{}, mapUniqueError(err)
+	}
 	if cmd.RowsAffected() == 0 {
 		return domain.Product{}, ErrNotFound
 	}
+	if _, err := tx.Exec(ctx, "UPDATE products SET category_id = $2 WHERE parent_id = $1", id, p.Category.ID); err != nil {
+		return domain.Product{}, err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM product_barcodes WHERE product_id = $1", id); err != nil {
+		return domain.Product{}, err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM product_units WHERE product_id = $1", id); err != nil {
+		return domain.Product{}, err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM product_components WHERE bundle_id = $1", id); err != nil {
+		return domain.Product{}, err
+	}
+	if err := setBarcodes(ctx, tx, id, p.Barcodes); err != nil {
+		return domain.Product{}, err
+	}
+	if err := setUnits(ctx, tx, id, p.Units); err != nil {
+		return domain.Product{}, err
+	}
+	if err := setComponents(ctx, tx, id, p.Components); err != nil {
+		return domain.Product{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Product{}, err
+	}
 	updated, err := r.GetByID(id)
 	if err != nil {
 		return domain.Product{}, err
@@ -112,14 +264,128 @@ func (r *ProductPG) Update(id int, p domain.Product) (domain.Product, error) {
 	return *updated, nil
 }
 
-// Delete removes a product by ID. Returns ErrNotFound if no rows affected.
+// Delete soft-deletes a product by ID together with its variants, hiding them from all reads.
+// Returns ErrNotFound if it does not exist or is already deleted.
 func (r *ProductPG) Delete(id int) error {
-	cmd, err := r.pool.Exec(context.Background(), "DELETE FROM products WHERE id = $1", id)
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx,
+		"UPDATE products SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		return err
 	}
 	if cmd.RowsAffected() == 0 {
 		return ErrNotFound
 	}
+	if _, err := tx.Exec(ctx,
+		"UPDATE products SET deleted_at = now() WHERE parent_id = $1 AND deleted_at IS NULL", id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Restore undeletes a soft-deleted product. Returns ErrNotFound if there is no deleted product with that ID.
+func (r *ProductPG) Restore(id int) error {
+	cmd, err := r.pool.Exec(context.Background(),
+		"UPDATE products SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Purge permanently removes soft-deleted products that no transaction, cart, bundle or remaining
+// variant refers to and returns how many were removed. A parent whose variants are purged in the same run
+// goes on the next run.
+func (r *ProductPG) Purge() (int, error) {
+	cmd, err := r.pool.Exec(context.Background(),
+		`DELETE FROM products p
+		 WHERE p.deleted_at IS NOT NULL
+		   AND NOT EXISTS (SELECT 1 FROM transaction_details td WHERE td.product_id = p.id)
+		   AND NOT EXISTS (SELECT 1 FROM cart_items ci WHERE ci.product_id = p.id)
+		   AND NOT EXISTS (SELECT 1 FROM products v WHERE v.parent_id = p.id)
+		   AND NOT EXISTS (SELECT 1 FROM product_components pc WHERE pc.component_id = p.id)
+		   AND NOT EXISTS (SELECT 1 FROM transaction_detail_components tdc WHERE tdc.product_id = p.id)`)
+	if err != nil {
+		return 0, err
+	}
+	return int(cmd.RowsAffected()), nil
+}
+
+// setBarcodes inserts the barcodes of product id. Returns an error wrapping ErrDuplicate if one
+// belongs to another product.
+func setBarcodes(ctx context.Context, tx pgx.Tx, id int, codes []string) error {
+	for _, code := range codes {
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO product_barcodes (barcode, product_id) VALUES ($1, $2)", code, id); err != nil {
+			return mapUniqueError(err)
+		}
+	}
+	return nil
+}
+
+// variantAttributes returns attrs for the NOT NULL variant_attributes column.
+func variantAttributes(attrs []string) []string {
+	if attrs == nil {
+		return []string{}
+	}
+	return attrs
+}
+
+// attributesJSON encodes a variant's attribute values for the attributes column; none is {}.
+func attributesJSON(attrs map[string]string) (string, error) {
+	if len(attrs) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(attrs)
+	return string(b), err
+}
+
+// setUnits inserts the selling units of product id.
+func setUnits(ctx context.Context, tx pgx.Tx, id int, units []domain.ProductUnit) error {
+	for _, u := range units {
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO product_units (product_id, name, factor, harga) VALUES ($1, $2, $3, $4)",
+			id, u.Name, u.Factor, u.Harga); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// setComponents inserts the bill of materials of bundle id.
+func setComponents(ctx context.Context, tx pgx.Tx, id int, components []domain.BundleComponent) error {
+	for _, c := range components {
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO product_components (bundle_id, component_id, quantity) VALUES ($1, $2, $3)",
+			id, c.ProductID, c.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapUniqueError turns a unique violation on a product SKU or barcode into an error wrapping ErrDuplicate.
+func mapUniqueError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return err
+	}
+	switch pgErr.ConstraintName {
+	case "idx_products_sku":
+		return fmt.Errorf("sku %w", ErrDuplicate)
+	case "product_barcodes_pkey":
+		return fmt.Errorf("barcode %w", ErrDuplicate)
+	case "idx_products_variant_attributes":
+		return fmt.Errorf("variant %w", ErrDuplicate)
+	}
+	return fmt.Errorf("%w: %s", ErrDuplicate, pgErr.Detail)
+}
@@ -4,9 +4,19 @@ import "kasir-api/internal/domain"
 
 // ProductRepository defines the interface for product data access.
 type ProductRepository interface {
-	GetAll(name string) ([]domain.Product, error)
+	GetAll(f domain.ProductFilter) ([]domain.Product, error)
 	GetByID(id int) (*domain.Product, error)
+	GetByIDs(ids []int) ([]domain.Product, error)
+	// GetByBarcodes returns the products carrying the given barcodes, keyed by barcode. Unknown
+	// barcodes are skipped.
+	GetByBarcodes(codes []string) (map[string]domain.Product, error)
+	// Create and Update return an error wrapping ErrDuplicate if the SKU or a barcode belongs to another product.
 	Create(p domain.Product) (domain.Product, error)
-	Update(id int, p domain.Product) (domain.Product, error)
+	// Update ignores p.Stok: stock is set to *stock, or left as it is if stock is nil.
+	Update(id int, p domain.Product, stock *domain.Quantity) (domain.Product, error)
+	// Delete soft-deletes a product; deleted products are hidden from every other method.
 	Delete(id int) error
+	Restore(id int) error
+	// Purge permanently removes deleted products that are not referenced by any transaction.
+	Purge() (int, error)
 }
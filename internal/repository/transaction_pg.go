@@ -2,135 +2,771 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"kasir-api/internal/domain"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // TransactionPG is a PostgreSQL implementation of TransactionRepository.
 type TransactionPG struct {
-	pool *pgxpool.Pool
+	pool      *pgxpool.Pool
+	numbering domain.ReceiptNumbering
 }
 
-// NewTransactionPG creates a new PostgreSQL transaction repository.
-func NewTransactionPG(pool *pgxpool.Pool) *TransactionPG {
-	return &TransactionPG{pool: pool}
+// NewTransactionPG creates a new PostgreSQL transaction repository that numbers receipts with numbering.
+func NewTransactionPG(pool *pgxpool.Pool, numbering domain.ReceiptNumbering) *TransactionPG {
+	return &TransactionPG{pool: pool, numbering: numbering}
 }
 
-// CreateTransaction creates a transaction from checkout items in a single DB transaction:
-// for each item loads product (name, price, stock), decrements stock, builds details and totals,
-// inserts the transaction and its details, then commits.
-func (r *TransactionPG) CreateTransaction(items []domain.CheckoutItem) (*domain.Transaction, error) {
-	tx, err := r.pool.Begin(context.Background())
+// CreateTransaction persists a priced draft transaction in a single DB transaction: checks that
+// enough stock is left for every detail line, decrements stock, inserts the transaction, its details
+// and payments, then commits. If any product is short, nothing is written and *ErrInsufficientStock
+// lists every short item. draft is not modified; the stored transaction is returned.
+//
+// With useLock, all product rows are locked up front with SELECT ... FOR UPDATE in ascending id
+// order, so concurrent checkouts queue behind each other without deadlocking. Without it, stock is
+// read from a REPEATABLE READ snapshot and decremented with conditional updates; a concurrent write
+// to the same rows fails with ErrSerialization and the caller may retry.
+//
+// The transaction gets the next receipt number of its outlet and period from receipt_sequences, in
+// the same DB transaction, so a number is only used by a committed sale and numbers are gap-free.
+// The counter row is locked after the product rows. Optimistic checkouts made at the same time
+// conflict on it and fail with ErrSerialization like any other concurrent write.
+//
+// If draft.CartID is set, the cart is marked checked out in the same DB transaction (ErrCartNotOpen
+// if it is no longer open) and its reserved stock is returned before stock is checked, so a
+// reserving cart always has its own stock available.
+//
+// If draft.IdempotencyKey is set, the new transaction is stored as the key's response in the same
+// DB transaction, so a committed sale is never left without it. The key must still be reserved.
+func (r *TransactionPG) CreateTransaction(draft *domain.Transaction, useLock bool) (*domain.Transaction, error) {
+	ctx := context.Background()
+	txOpts := pgx.TxOptions{}
+	if !useLock {
+		txOpts.IsoLevel = pgx.RepeatableRead
+	}
+	tx, err := r.pool.BeginTx(ctx, txOpts)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
-	totalAmount := 0
-	details := make([]domain.TransactionDetail, 0, len(items))
+	if draft.CartID != 0 {
+		cmd, err := tx.Exec(ctx,
+			`UPDATE carts SET status = 'checked_out', updated_at = now()
+			 WHERE id = $1 AND status = 'open' AND expires_at > now()`, draft.CartID)
+		if err != nil {
+			return nil, mapTxError(err)
+		}
+		if cmd.RowsAffected() == 0 {
+			return nil, ErrCartNotOpen
+		}
+		if err := releaseCartStock(ctx, tx, draft.CartID); err != nil {
+			return nil, err
+		}
+	}
 
-	for _, item := range items {
-		var productPrice, stock int
-		var productName string
+	// Bundles take their stock from their components.
+	requested := make(map[int]domain.Quantity)
+	ids := make([]int, 0, len(draft.Details))
+	for _, d := range draft.Details {
+		for _, use := range d.StockUsage() {
+			if _, ok := requested[use.ProductID]; !ok {
+				ids = append(ids, use.ProductID)
+			}
+			requested[use.ProductID] += use.Quantity
+		}
+	}
+	sort.Ints(ids)
+
+	stock, err := r.readStock(ctx, tx, ids, useLock)
+	if err != nil {
+		return nil, err
+	}
 
-		err := tx.QueryRow(context.Background(),
-			"SELECT nama, harga, stok FROM products WHERE id = $1", item.ProductID).
-			Scan(&productName, &productPrice, &stock)
+	// Check every product before touching stock so the caller gets the full list of short items.
+	var short []domain.StockShortage
+	for _, id := range ids {
+		available, ok := stock[id]
+		if !ok {
+			return nil, fmt.Errorf("product id %d %w", id, ErrNotFound)
+		}
+		if requested[id] > available {
+			short = append(short, domain.StockShortage{
+				ProductID: id,
+				Requested: requested[id],
+				Available: available,
+			})
+		}
+	}
+	if len(short) > 0 {
+		return nil, &ErrInsufficientStock{Items: short}
+	}
+
+	for _, id := range ids {
+		// The stok >= $1 guard keeps stock from going negative even if the row changed since it was read.
+		cmd, err := tx.Exec(ctx,
+			"UPDATE products SET stok = stok - $1 WHERE id = $2 AND stok >= $1", requested[id], id)
+		if err != nil {
+			return nil, mapTxError(err)
+		}
+		if cmd.RowsAffected() == 0 {
+			return nil, ErrSerialization
+		}
+	}
+
+	out := *draft
+	out.Status = domain.TransactionStatusCompleted
+	out.Details = append([]domain.TransactionDetail(nil), draft.Details...)
+	out.Payments = append([]domain.Payment(nil), draft.Payments...)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO transactions (gross_amount, discount_amount, cart_discount_amount, net_amount,
+		     service_charge_amount, tax_amount, total_amount, tax_rate, tax_inclusive, service_charge_rate, cart_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, 0)) RETURNING id, receipt_token, created_at`,
+		out.GrossAmount, out.DiscountAmount, out.CartDiscountAmount, out.NetAmount,
+		out.ServiceChargeAmount, out.TaxAmount, out.TotalAmount, out.TaxRate, out.TaxInclusive, out.ServiceChargeRate,
+		out.CartID).
+		Scan(&out.ID, &out.ReceiptToken, &out.CreatedAt)
+	if err != nil {
+		return nil, mapTxError(err)
+	}
+
+	for i := range out.Details {
+		out.Details[i].TransactionID = out.ID
+		err = tx.QueryRow(ctx,
+			`INSERT INTO transaction_details (transaction_id, product_id, product_name, unit_price, unit_cost,
+			     quantity, unit, unit_factor, gross_amount, discount_amount, subtotal)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+			out.ID, out.Details[i].ProductID, out.Details[i].ProductName, out.Details[i].UnitPrice, out.Details[i].UnitCost,
+			out.Details[i].Quantity, out.Details[i].Unit, out.Details[i].UnitFactor, out.Details[i].GrossAmount, out.Details[i].DiscountAmount, out.Details[i].Subtotal).
+			Scan(&out.Details[i].ID)
 		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, fmt.Errorf("product id %d not found", item.ProductID)
+			return nil, mapTxError(err)
+		}
+		for _, c := range out.Details[i].Components {
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO transaction_detail_components (transaction_detail_id, product_id, product_name, quantity)
+				 VALUES ($1, $2, $3, $4)`,
+				out.Details[i].ID, c.ProductID, c.ProductName, c.Quantity); err != nil {
+				return nil, mapTxError(err)
+			}
+		}
+		for pos, m := range out.Details[i].Modifiers {
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO transaction_detail_modifiers (transaction_detail_id, position, modifier_id, group_name, nama, harga)
+				 VALUES ($1, $2, $3, $4, $5, $6)`,
+				out.Details[i].ID, pos, m.ModifierID, m.Group, m.Nama, m.Harga); err != nil {
+				return nil, mapTxError(err)
 			}
+		}
+	}
+
+	for i := range out.Payments {
+		out.Payments[i].TransactionID = out.ID
+		err = tx.QueryRow(ctx,
+			`INSERT INTO payments (transaction_id, method, amount, tendered, change_amount)
+			 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			out.ID, out.Payments[i].Method, out.Payments[i].Amount, out.Payments[i].Tendered, out.Payments[i].Change).
+			Scan(&out.Payments[i].ID)
+		if err != nil {
+			return nil, mapTxError(err)
+		}
+	}
+
+	if out.ReceiptNumber, err = r.assignReceiptNumber(ctx, tx, out.ID, out.CreatedAt); err != nil {
+		return nil, mapTxError(err)
+	}
+
+	if out.CartID != 0 {
+		if _, err := tx.Exec(ctx, "UPDATE carts SET transaction_id = $1 WHERE id = $2", out.ID, out.CartID); err != nil {
+			return nil, mapTxError(err)
+		}
+	}
+
+	if out.IdempotencyKey != "" {
+		response, err := json.Marshal(&out)
+		if err != nil {
 			return nil, err
 		}
+		cmd, err := tx.Exec(ctx,
+			"UPDATE idempotency_keys SET transaction_id = $2, response = $3 WHERE key = $1 AND response IS NULL",
+			out.IdempotencyKey, out.ID, string(response))
+		if err != nil {
+			return nil, mapTxError(err)
+		}
+		if cmd.RowsAffected() == 0 {
+			return nil, fmt.Errorf("idempotency key %q is no longer reserved", out.IdempotencyKey)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, mapTxError(err)
+	}
+	return &out, nil
+}
+
+// assignReceiptNumber takes the next number of the receipt counter for a transaction made at
+// createdAt and stores it on transaction id. The counter row stays locked until tx ends.
+func (r *TransactionPG) assignReceiptNumber(ctx context.Context, tx pgx.Tx, id int, createdAt time.Time) (string, error) {
+	var seq int
+	err := tx.QueryRow(ctx,
+		`INSERT INTO receipt_sequences (scope, last_value) VALUES ($1, 1)
+		 ON CONFLICT (scope) DO UPDATE SET last_value = receipt_sequences.last_value + 1
+		 RETURNING last_value`, r.numbering.Scope(createdAt.Local())).Scan(&seq)
+	if err != nil {
+		return "", err
+	}
+	number := r.numbering.Format(createdAt.Local(), seq)
+	if _, err := tx.Exec(ctx, "UPDATE transactions SET receipt_number = $1 WHERE id = $2", number, id); err != nil {
+		return "", err
+	}
+	return number, nil
+}
+
+// readStock returns the current stock of the given products, locking their rows when lock is set.
+// ids must be sorted so that concurrent lockers acquire rows in the same order.
+func (r *TransactionPG) readStock(ctx context.Context, tx pgx.Tx, ids []int, lock bool) (map[int]domain.Quantity, error) {
+	query := "SELECT id, stok FROM products WHERE id = ANY($1) ORDER BY id"
+	if lock {
+		query += " FOR UPDATE"
+	}
+	rows, err := tx.Query(ctx, query, ids)
+	if err != nil {
+		return nil, mapTxError(err)
+	}
+	defer rows.Close()
+
+	stock := make(map[int]domain.Quantity, len(ids))
+	for rows.Next() {
+		var id int
+		var stok domain.Quantity
+		if err := rows.Scan(&id, &stok); err != nil {
+			return nil, mapTxError(err)
+		}
+		stock[id] = stok
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapTxError(err)
+	}
+	return stock, nil
+}
 
-		subtotal := productPrice * item.Quantity
-		totalAmount += subtotal
+// detailComponents selects the components of the transaction detail td as a JSON array.
+const detailComponents = `COALESCE((SELECT json_agg(json_build_object('product_id', c.product_id,
+	'product_name', c.product_name, 'quantity', c.quantity) ORDER BY c.product_id)
+	FROM transaction_detail_components c WHERE c.transaction_detail_id = td.id), '[]')`
 
-		_, err = tx.Exec(context.Background(),
-			"UPDATE products SET stok = stok - $1 WHERE id = $2", item.Quantity, item.ProductID)
+// detailModifiers selects the modifiers of the transaction detail td as a JSON array, in the order they were sold.
+const detailModifiers = `COALESCE((SELECT json_agg(json_build_object('modifier_id', m.modifier_id,
+	'group', m.group_name, 'nama', m.nama, 'harga', m.harga) ORDER BY m.position)
+	FROM transaction_detail_modifiers m WHERE m.transaction_detail_id = td.id), '[]')`
+
+// transactionColumns are the transactions columns read by scanTransaction, for the alias t.
+const transactionColumns = `t.id, t.gross_amount, t.discount_amount, t.cart_discount_amount, t.net_amount,
+	t.service_charge_amount, t.tax_amount, t.total_amount, t.tax_rate, t.tax_inclusive, t.service_charge_rate,
+	t.status, COALESCE(t.receipt_number, ''), t.receipt_token, COALESCE(t.cart_id, 0), t.created_at`
+
+// scanTransaction scans transactionColumns followed by any extra destinations.
+func scanTransaction(scan func(...any) error, extra ...any) (domain.Transaction, error) {
+	var t domain.Transaction
+	dest := append([]any{&t.ID, &t.GrossAmount, &t.DiscountAmount, &t.CartDiscountAmount, &t.NetAmount,
+		&t.ServiceChargeAmount, &t.TaxAmount, &t.TotalAmount, &t.TaxRate, &t.TaxInclusive, &t.ServiceChargeRate,
+		&t.Status, &t.ReceiptNumber, &t.ReceiptToken, &t.CartID, &t.CreatedAt}, extra...)
+	if err := scan(dest...); err != nil {
+		return domain.Transaction{}, err
+	}
+	return t, nil
+}
+
+// ListTransactions returns one page of transactions (without details) matching f, newest first,
+// together with the total number of matching transactions.
+func (r *TransactionPG) ListTransactions(f domain.TransactionFilter) ([]domain.Transaction, int, error) {
+	ctx := context.Background()
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.From != nil {
+		conds = append(conds, "t.created_at >= "+arg(*f.From))
+	}
+	if f.To != nil {
+		conds = append(conds, "t.created_at < "+arg(*f.To))
+	}
+	if f.MinTotal != nil {
+		conds = append(conds, "t.total_amount >= "+arg(*f.MinTotal))
+	}
+	if f.MaxTotal != nil {
+		conds = append(conds, "t.total_amount <= "+arg(*f.MaxTotal))
+	}
+	if f.ProductID != 0 {
+		conds = append(conds, "EXISTS (SELECT 1 FROM transaction_details td WHERE td.transaction_id = t.id AND td.product_id = "+arg(f.ProductID)+")")
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM transactions t"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + transactionColumns + `,
+		COALESCE((SELECT SUM(pm.change_amount) FROM payments pm WHERE pm.transaction_id = t.id), 0)
+		FROM transactions t` + where +
+		" ORDER BY t.created_at DESC, t.id DESC LIMIT " + arg(f.Limit) + " OFFSET " + arg((f.Page-1)*f.Limit)
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := []domain.Transaction{}
+	for rows.Next() {
+		var change int
+		t, err := scanTransaction(rows.Scan, &change)
 		if err != nil {
+			return nil, 0, err
+		}
+		t.Change = change
+		out = append(out, t)
+	}
+	return out, total, rows.Err()
+}
+
+// GetTransactionByID returns a transaction with its details (as snapshotted at sale time),
+// payments and refunds, or ErrNotFound.
+func (r *TransactionPG) GetTransactionByID(id int) (*domain.Transaction, error) {
+	return r.getTransaction("t.id = $1", id)
+}
+
+// GetTransactionByReceiptNumber returns a transaction by its receipt number, like GetTransactionByID.
+func (r *TransactionPG) GetTransactionByReceiptNumber(number string) (*domain.Transaction, error) {
+	return r.getTransaction("t.receipt_number = $1", number)
+}
+
+// GetTransactionByReceiptToken returns a transaction by its receipt token, like GetTransactionByID.
+func (r *TransactionPG) GetTransactionByReceiptToken(token string) (*domain.Transaction, error) {
+	return r.getTransaction("t.receipt_token = $1", token)
+}
+
+// getTransaction loads the one transaction matching where, with its details, payments and refunds.
+func (r *TransactionPG) getTransaction(where string, arg any) (*domain.Transaction, error) {
+	ctx := context.Background()
+	row := r.pool.QueryRow(ctx, "SELECT "+transactionColumns+" FROM transactions t WHERE "+where, arg)
+	t, err := scanTransaction(row.Scan)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	id := t.ID
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT td.id, td.transaction_id, td.product_id, td.product_name, td.unit_price, td.unit_cost, td.quantity,
+		        td.unit, td.unit_factor, td.gross_amount, td.discount_amount, td.subtotal,
+		        COALESCE((SELECT SUM(ri.quantity) FROM refund_items ri WHERE ri.transaction_detail_id = td.id), 0),
+		        `+detailComponents+`, `+detailModifiers+`
+		 FROM transaction_details td
+		 WHERE td.transaction_id = $1
+		 ORDER BY td.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d domain.TransactionDetail
+		if err := rows.Scan(&d.ID, &d.TransactionID, &d.ProductID, &d.ProductName, &d.UnitPrice, &d.UnitCost, &d.Quantity,
+			&d.Unit, &d.UnitFactor, &d.GrossAmount, &d.DiscountAmount, &d.Subtotal, &d.RefundedQuantity,
+			&d.Components, &d.Modifiers); err != nil {
 			return nil, err
 		}
+		t.Details = append(t.Details, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		details = append(details, domain.TransactionDetail{
-			ProductID:   item.ProductID,
-			ProductName: productName,
-			Quantity:    item.Quantity,
-			Subtotal:    subtotal,
-		})
+	t.Payments, err = r.getPayments(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range t.Payments {
+		t.Change += p.Change
+	}
+
+	t.Refunds, err = r.getRefunds(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// getPayments loads the payments of a transaction in the order they were recorded.
+func (r *TransactionPG) getPayments(ctx context.Context, transactionID int) ([]domain.Payment, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, transaction_id, method, amount, tendered, change_amount
+		 FROM payments WHERE transaction_id = $1 ORDER BY id`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Payment
+	for rows.Next() {
+		var p domain.Payment
+		if err := rows.Scan(&p.ID, &p.TransactionID, &p.Method, &p.Amount, &p.Tendered, &p.Change); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
 	}
+	return out, rows.Err()
+}
 
-	var transactionID int
-	var createdAt time.Time
-	err = tx.QueryRow(context.Background(),
-		"INSERT INTO transactions (total_amount) VALUES ($1) RETURNING id, created_at", totalAmount).
-		Scan(&transactionID, &createdAt)
+// getRefunds loads all refunds of a transaction with their items, oldest first.
+func (r *TransactionPG) getRefunds(ctx context.Context, transactionID int) ([]domain.Refund, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT rf.id, rf.transaction_id, rf.type, rf.amount, rf.reason, rf.performed_by, rf.created_at,
+		        ri.id, ri.transaction_detail_id, td.product_id, ri.quantity, ri.amount
+		 FROM refunds rf
+		 JOIN refund_items ri ON ri.refund_id = rf.id
+		 JOIN transaction_details td ON td.id = ri.transaction_detail_id
+		 WHERE rf.transaction_id = $1
+		 ORDER BY rf.id, ri.id`, transactionID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	for i := range details {
-		details[i].TransactionID = transactionID
-		err = tx.QueryRow(context.Background(),
-			"INSERT INTO transaction_details (transaction_id, product_id, quantity, subtotal) VALUES ($1, $2, $3, $4) RETURNING id",
-			transactionID, details[i].ProductID, details[i].Quantity, details[i].Subtotal).
-			Scan(&details[i].ID)
+	var out []domain.Refund
+	for rows.Next() {
+		var rf domain.Refund
+		var it domain.RefundItem
+		if err := rows.Scan(&rf.ID, &rf.TransactionID, &rf.Type, &rf.Amount, &rf.Reason, &rf.PerformedBy, &rf.CreatedAt,
+			&it.ID, &it.TransactionDetailID, &it.ProductID, &it.Quantity, &it.Amount); err != nil {
+			return nil, err
+		}
+		it.RefundID = rf.ID
+		if n := len(out); n > 0 && out[n-1].ID == rf.ID {
+			out[n-1].Items = append(out[n-1].Items, it)
+			continue
+		}
+		rf.Items = []domain.RefundItem{it}
+		out = append(out, rf)
+	}
+	return out, rows.Err()
+}
+
+// VoidTransaction cancels everything not yet refunded on a transaction: restores stock,
+// records a void refund and marks the transaction voided, all in one DB transaction.
+func (r *TransactionPG) VoidTransaction(id int, req domain.VoidRequest) (*domain.Refund, error) {
+	return r.refund(id, domain.RefundTypeVoid, req.Reason, req.PerformedBy, nil)
+}
+
+// RefundTransaction returns the given quantities of transaction detail lines: restores stock,
+// records the refund and marks the transaction refunded or partially refunded, all in one DB transaction.
+func (r *TransactionPG) RefundTransaction(id int, req domain.RefundRequest) (*domain.Refund, error) {
+	return r.refund(id, domain.RefundTypeRefund, req.Reason, req.PerformedBy, req.Items)
+}
+
+// refund implements VoidTransaction (lines == nil, refund everything left) and RefundTransaction.
+func (r *TransactionPG) refund(id int, kind, reason, performedBy string, lines []domain.RefundLine) (*domain.Refund, error) {
+	ctx := context.Background()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	var total int
+	err = tx.QueryRow(ctx, "SELECT status, total_amount FROM transactions WHERE id = $1 FOR UPDATE", id).
+		Scan(&status, &total)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if status == domain.TransactionStatusVoided {
+		return nil, ErrTransactionVoided
+	}
+
+	type soldLine struct {
+		productID int
+		quantity  domain.Quantity
+		factor    domain.Quantity
+		subtotal  int
+		refunded  domain.Quantity
+
+		components []domain.BundleComponent
+	}
+	sold := make(map[int]*soldLine)
+	var detailIDs []int
+	rows, err := tx.Query(ctx,
+		`SELECT td.id, td.product_id, td.quantity, td.unit_factor, td.subtotal,
+		        COALESCE((SELECT SUM(ri.quantity) FROM refund_items ri WHERE ri.transaction_detail_id = td.id), 0),
+		        `+detailComponents+`
+		 FROM transaction_details td
+		 WHERE td.transaction_id = $1
+		 ORDER BY td.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var detailID int
+		var l soldLine
+		if err := rows.Scan(&detailID, &l.productID, &l.quantity, &l.factor, &l.subtotal, &l.refunded, &l.components); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sold[detailID] = &l
+		detailIDs = append(detailIDs, detailID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Quantity to return per detail line.
+	returning := make(map[int]domain.Quantity)
+	if lines == nil {
+		for _, detailID := range detailIDs {
+			if left := sold[detailID].quantity - sold[detailID].refunded; left > 0 {
+				returning[detailID] = left
+			}
+		}
+		if len(returning) == 0 {
+			return nil, fmt.Errorf("%w: nothing left to refund", ErrInvalidRefund)
+		}
+	}
+	for _, line := range lines {
+		l, ok := sold[line.DetailID]
+		if !ok {
+			return nil, fmt.Errorf("%w: detail id %d is not part of transaction %d", ErrInvalidRefund, line.DetailID, id)
+		}
+		returning[line.DetailID] += line.Quantity
+		if left := l.quantity - l.refunded; returning[line.DetailID] > left {
+			return nil, fmt.Errorf("%w: detail id %d has %s refundable, requested %s",
+				ErrInvalidRefund, line.DetailID, left, returning[line.DetailID])
+		}
+	}
+
+	out := &domain.Refund{
+		TransactionID: id,
+		Type:          kind,
+		Reason:        reason,
+		PerformedBy:   performedBy,
+	}
+	// Line subtotals exclude service charge and any exclusive tax, so refunded line value is scaled
+	// from the sum of subtotals up to the transaction total. Both steps prorate cumulatively, so
+	// refunding every unit returns exactly the total.
+	sumSubtotal, refundedValue := 0, 0
+	for _, l := range sold {
+		sumSubtotal += l.subtotal
+		refundedValue += l.subtotal * int(l.refunded) / int(l.quantity)
+	}
+	scaled := func(v int) int {
+		if sumSubtotal == 0 {
+			return 0
+		}
+		return total * v / sumSubtotal
+	}
+
+	restock := make(map[int]domain.Quantity)
+	for _, detailID := range detailIDs {
+		qty, ok := returning[detailID]
+		if !ok {
+			continue
+		}
+		l := sold[detailID]
+		value := l.subtotal*int(l.refunded+qty)/int(l.quantity) - l.subtotal*int(l.refunded)/int(l.quantity)
+		amount := scaled(refundedValue+value) - scaled(refundedValue)
+		refundedValue += value
+		out.Amount += amount
+		out.Items = append(out.Items, domain.RefundItem{
+			TransactionDetailID: detailID,
+			ProductID:           l.productID,
+			Quantity:            qty,
+			Amount:              amount,
+		})
+		// Refunds count in the unit the line was sold in; stock is kept in base units. Bundles give
+		// back the share of what they took from each component.
+		if len(l.components) == 0 {
+			restock[l.productID] += qty.Mul(l.factor)
+		}
+		for _, c := range l.components {
+			restock[c.ProductID] += c.Quantity*(l.refunded+qty)/l.quantity - c.Quantity*l.refunded/l.quantity
+		}
+		l.refunded += qty
+	}
+
+	productIDs := make([]int, 0, len(restock))
+	for pid := range restock {
+		productIDs = append(productIDs, pid)
+	}
+	sort.Ints(productIDs)
+	for _, pid := range productIDs {
+		if _, err := tx.Exec(ctx, "UPDATE products SET stok = stok + $1 WHERE id = $2", restock[pid], pid); err != nil {
+			return nil, mapTxError(err)
+		}
+	}
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO refunds (transaction_id, type, amount, reason, performed_by)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		id, kind, out.Amount, reason, performedBy).
+		Scan(&out.ID, &out.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out.Items {
+		out.Items[i].RefundID = out.ID
+		err = tx.QueryRow(ctx,
+			"INSERT INTO refund_items (refund_id, transaction_detail_id, quantity, amount) VALUES ($1, $2, $3, $4) RETURNING id",
+			out.ID, out.Items[i].TransactionDetailID, out.Items[i].Quantity, out.Items[i].Amount).
+			Scan(&out.Items[i].ID)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if err := tx.Commit(context.Background()); err != nil {
+	newStatus := domain.TransactionStatusVoided
+	if kind == domain.RefundTypeRefund {
+		newStatus = domain.TransactionStatusRefunded
+		for _, l := range sold {
+			if l.refunded < l.quantity {
+				newStatus = domain.TransactionStatusPartiallyRefunded
+				break
+			}
+		}
+	}
+	if _, err := tx.Exec(ctx, "UPDATE transactions SET status = $2 WHERE id = $1", id, newStatus); err != nil {
 		return nil, err
 	}
 
-	return &domain.Transaction{
-		ID:          transactionID,
-		TotalAmount: totalAmount,
-		CreatedAt:   createdAt,
-		Details:     details,
-	}, nil
+	if err := tx.Commit(ctx); err != nil {
+		return nil, mapTxError(err)
+	}
+	return out, nil
+}
+
+// mapTxError converts PostgreSQL serialization and deadlock failures into ErrSerialization.
+func mapTxError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01") {
+		return ErrSerialization
+	}
+	return err
 }
 
-// GetSummaryHariIni returns today's sales summary: total revenue, transaction count, and best-selling product.
+// GetSummaryHariIni returns today's sales summary: revenue (without tax and service charge) net of
+// today's refunds, tax and service charge collected, the number of transactions that were not
+// voided, and the best-selling product by net quantity in its base unit. Discounts and the totals
+// per payment method are net of today's refunds too, so the method totals add up to revenue plus
+// tax and service charge.
 func (r *TransactionPG) GetSummaryHariIni() (*domain.SummaryHariIni, error) {
 	ctx := context.Background()
 	now := time.Now()
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
-	var totalRevenue int
+	var netSales, tax, service, totalDiskon int
 	var totalTransaksi int
 	err := r.pool.QueryRow(ctx,
-		"SELECT COALESCE(SUM(total_amount), 0), COUNT(*) FROM transactions WHERE created_at >= $1 AND created_at < $2",
+		`SELECT COALESCE(SUM(net_amount), 0), COALESCE(SUM(tax_amount), 0), COALESCE(SUM(service_charge_amount), 0),
+		        COUNT(*) FILTER (WHERE status <> 'voided'), COALESCE(SUM(discount_amount), 0)
+		 FROM transactions WHERE created_at >= $1 AND created_at < $2`,
+		startOfDay, endOfDay).
+		Scan(&netSales, &tax, &service, &totalTransaksi, &totalDiskon)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refunds give back tax and service charge in the same proportion as the refunded transaction.
+	var totalRefund, refundTax, refundService int
+	err = r.pool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(rf.amount), 0),
+		        COALESCE(SUM(ROUND(rf.amount::numeric * t.tax_amount / NULLIF(t.total_amount, 0))), 0)::int,
+		        COALESCE(SUM(ROUND(rf.amount::numeric * t.service_charge_amount / NULLIF(t.total_amount, 0))), 0)::int
+		 FROM refunds rf
+		 JOIN transactions t ON t.id = rf.transaction_id
+		 WHERE rf.created_at >= $1 AND rf.created_at < $2`,
+		startOfDay, endOfDay).
+		Scan(&totalRefund, &refundTax, &refundService)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refunded units give back their line's discount, prorated cumulatively like refund amounts so
+	// that refunding a whole line gives back exactly its discount.
+	var refundDiskon int
+	err = r.pool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(FLOOR(td.discount_amount * ri.upto / td.quantity)
+		                   - FLOOR(td.discount_amount * (ri.upto - ri.quantity) / td.quantity)), 0)::int
+		 FROM (SELECT ri.refund_id, ri.transaction_detail_id, ri.quantity,
+		              SUM(ri.quantity) OVER (PARTITION BY ri.transaction_detail_id ORDER BY ri.id) AS upto
+		       FROM refund_items ri) ri
+		 JOIN refunds rf ON rf.id = ri.refund_id
+		 JOIN transaction_details td ON td.id = ri.transaction_detail_id
+		 WHERE rf.created_at >= $1 AND rf.created_at < $2`,
 		startOfDay, endOfDay).
-		Scan(&totalRevenue, &totalTransaksi)
+		Scan(&refundDiskon)
 	if err != nil {
 		return nil, err
 	}
 
 	out := &domain.SummaryHariIni{
-		TotalRevenue:   totalRevenue,
-		TotalTransaksi: totalTransaksi,
-		ProdukTerlaris: domain.ProdukTerlaris{},
+		TotalRevenue:       netSales - (totalRefund - refundTax - refundService),
+		TotalRefund:        totalRefund,
+		TotalDiskon:        totalDiskon - refundDiskon,
+		TotalPajak:         tax - refundTax,
+		TotalServiceCharge: service - refundService,
+		TotalTransaksi:     totalTransaksi,
+		ProdukTerlaris:     domain.ProdukTerlaris{},
+		PerMetode:          []domain.PaymentTotal{},
+		PaketTerjual:       []domain.ProductQty{},
+		PemakaianKomponen:  []domain.ProductQty{},
 	}
 
+	if out.PerMetode, err = r.paymentTotals(ctx, startOfDay, endOfDay); err != nil {
+		return nil, err
+	}
+
+	// Quantities are grouped by product; the name is the snapshot from the most recent sale.
 	var nama string
-	var qtyTerjual int
+	var qtyTerjual domain.Quantity
 	err = r.pool.QueryRow(ctx,
-		`SELECT p.nama, COALESCE(SUM(td.quantity), 0)
-		 FROM transaction_details td
-		 JOIN transactions t ON t.id = td.transaction_id
-		 JOIN products p ON p.id = td.product_id
-		 WHERE t.created_at >= $1 AND t.created_at < $2
-		 GROUP BY td.product_id, p.nama
-		 ORDER BY SUM(td.quantity) DESC
+		`WITH moved AS (
+		     SELECT td.id AS detail_id, td.product_id, td.product_name, ROUND(td.quantity * td.unit_factor, 3) AS qty
+		     FROM transaction_details td
+		     JOIN transactions t ON t.id = td.transaction_id
+		     WHERE t.created_at >= $1 AND t.created_at < $2
+		     UNION ALL
+		     SELECT td.id, td.product_id, td.product_name, -ROUND(ri.quantity * td.unit_factor, 3)
+		     FROM refund_items ri
+		     JOIN refunds rf ON rf.id = ri.refund_id
+		     JOIN transaction_details td ON td.id = ri.transaction_detail_id
+		     WHERE rf.created_at >= $1 AND rf.created_at < $2
+		 )
+		 SELECT (array_agg(m.product_name ORDER BY m.detail_id DESC))[1], SUM(m.qty)
+		 FROM moved m
+		 GROUP BY m.product_id
+		 HAVING SUM(m.qty) > 0
+		 ORDER BY SUM(m.qty) DESC
 		 LIMIT 1`,
 		startOfDay, endOfDay).
 		Scan(&nama, &qtyTerjual)
@@ -141,5 +777,182 @@ func (r *TransactionPG) GetSummaryHariIni() (*domain.SummaryHariIni, error) {
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, err
 	}
+
+	out.PaketTerjual, err = r.productQtys(ctx,
+		`WITH moved AS (
+		     SELECT td.id AS detail_id, td.product_id, td.product_name, ROUND(td.quantity * td.unit_factor, 3) AS qty
+		     FROM transaction_details td
+		     JOIN transactions t ON t.id = td.transaction_id
+		     WHERE t.created_at >= $1 AND t.created_at < $2
+		       AND EXISTS (SELECT 1 FROM transaction_detail_components c WHERE c.transaction_detail_id = td.id)
+		     UNION ALL
+		     SELECT td.id, td.product_id, td.product_name, -ROUND(ri.quantity * td.unit_factor, 3)
+		     FROM refund_items ri
+		     JOIN refunds rf ON rf.id = ri.refund_id
+		     JOIN transaction_details td ON td.id = ri.transaction_detail_id
+		     WHERE rf.created_at >= $1 AND rf.created_at < $2
+		       AND EXISTS (SELECT 1 FROM transaction_detail_components c WHERE c.transaction_detail_id = td.id)
+		 )`, startOfDay, endOfDay)
+	if err != nil {
+		return nil, err
+	}
+	// A refund gives back the same share of each component as of the bundle line.
+	out.PemakaianKomponen, err = r.productQtys(ctx,
+		`WITH moved AS (
+		     SELECT c.transaction_detail_id AS detail_id, c.product_id, c.product_name, c.quantity AS qty
+		     FROM transaction_detail_components c
+		     JOIN transaction_details td ON td.id = c.transaction_detail_id
+		     JOIN transactions t ON t.id = td.transaction_id
+		     WHERE t.created_at >= $1 AND t.created_at < $2
+		     UNION ALL
+		     SELECT c.transaction_detail_id, c.product_id, c.product_name, -ROUND(c.quantity * ri.quantity / td.quantity, 3)
+		     FROM refund_items ri
+		     JOIN refunds rf ON rf.id = ri.refund_id
+		     JOIN transaction_details td ON td.id = ri.transaction_detail_id
+		     JOIN transaction_detail_components c ON c.transaction_detail_id = td.id
+		     WHERE rf.created_at >= $1 AND rf.created_at < $2
+		 )`, startOfDay, endOfDay)
+	if err != nil {
+		return nil, err
+	}
 	return out, nil
 }
+
+// paymentTotals returns what was paid with each method for the transactions made in [from, to),
+// less the refunds made in [from, to). A refund goes back to the methods its transaction was paid
+// with, as split by splitRefund. Methods that net to nothing are left out.
+func (r *TransactionPG) paymentTotals(ctx context.Context, from, to time.Time) ([]domain.PaymentTotal, error) {
+	totals := make(map[string]*domain.PaymentTotal)
+	total := func(method string) *domain.PaymentTotal {
+		if totals[method] == nil {
+			totals[method] = &domain.PaymentTotal{Method: method}
+		}
+		return totals[method]
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT pm.method, SUM(pm.amount), COUNT(DISTINCT pm.transaction_id) FILTER (WHERE t.status <> 'voided')
+		 FROM payments pm
+		 JOIN transactions t ON t.id = pm.transaction_id
+		 WHERE t.created_at >= $1 AND t.created_at < $2
+		 GROUP BY pm.method`,
+		from, to)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var method string
+		var amount, count int
+		if err := rows.Scan(&method, &amount, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pt := total(method)
+		pt.Total += amount
+		pt.TotalTransaksi += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Each refund of the period with what its transaction had been refunded before it, and the
+	// transaction's payments in the order they were made.
+	rows, err = r.pool.Query(ctx,
+		`SELECT rf.upto - rf.amount, rf.upto,
+		        (SELECT array_agg(pm.method ORDER BY pm.id) FROM payments pm WHERE pm.transaction_id = rf.transaction_id),
+		        (SELECT array_agg(pm.amount ORDER BY pm.id) FROM payments pm WHERE pm.transaction_id = rf.transaction_id)
+		 FROM (SELECT rf.transaction_id, rf.amount, rf.created_at,
+		              SUM(rf.amount) OVER (PARTITION BY rf.transaction_id ORDER BY rf.id) AS upto
+		       FROM refunds rf
+		       WHERE rf.transaction_id IN (SELECT transaction_id FROM refunds WHERE created_at >= $1 AND created_at < $2)) rf
+		 WHERE rf.created_at >= $1 AND rf.created_at < $2`,
+		from, to)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var before, after int
+		var methods []string
+		var paid []int
+		if err := rows.Scan(&before, &after, &methods, &paid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for i, share := range splitRefund(paid, before, after) {
+			total(methods[i]).Total -= share
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := []domain.PaymentTotal{}
+	for _, pt := range totals {
+		if pt.Total != 0 || pt.TotalTransaksi != 0 {
+			out = append(out, *pt)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Method < out[j].Method })
+	return out, nil
+}
+
+// splitRefund splits the part of a refund between before and after, the amounts a transaction had
+// been refunded in total before and after it, over the payments paid of the transaction. Each
+// payment takes back its share of the refunded total, rounded down, and the last one takes the
+// rounding rest; splitting cumulatively keeps the shares of every refund of a transaction adding
+// up to exactly what was paid with each payment once it is fully refunded. A transaction without
+// payments gets no shares.
+func splitRefund(paid []int, before, after int) []int {
+	sum := 0
+	for _, p := range paid {
+		sum += p
+	}
+	if sum == 0 {
+		return make([]int, len(paid))
+	}
+	upTo := func(refunded int) []int {
+		out := make([]int, len(paid))
+		rest := refunded
+		for i, p := range paid {
+			if i == len(paid)-1 {
+				out[i] = rest
+				break
+			}
+			out[i] = refunded * p / sum
+			rest -= out[i]
+		}
+		return out
+	}
+	shares, prev := upTo(after), upTo(before)
+	for i := range shares {
+		shares[i] -= prev[i]
+	}
+	return shares
+}
+
+// productQtys totals the quantities of the moved CTE opened by with (detail_id, product_id,
+// product_name, qty) per product, largest first, naming each product as in its most recent line.
+// Products that net to zero are left out.
+func (r *TransactionPG) productQtys(ctx context.Context, with string, from, to time.Time) ([]domain.ProductQty, error) {
+	rows, err := r.pool.Query(ctx, with+`
+		 SELECT m.product_id, (array_agg(m.product_name ORDER BY m.detail_id DESC))[1], SUM(m.qty)
+		 FROM moved m
+		 GROUP BY m.product_id
+		 HAVING SUM(m.qty) <> 0
+		 ORDER BY SUM(m.qty) DESC, m.product_id`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.ProductQty{}
+	for rows.Next() {
+		var pq domain.ProductQty
+		if err := rows.Scan(&pq.ProductID, &pq.Nama, &pq.Qty); err != nil {
+			return nil, err
+		}
+		out = append(out, pq)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitRefund(t *testing.T) {
+	// A 68.182 sale paid 50.000 by QRIS and 18.182 in cash, refunded in three parts.
+	paid := []int{50000, 18182}
+	refunds := []int{10001, 333, 57848}
+	wantShares := [][]int{{7334, 2667}, {244, 89}, {42422, 15426}}
+
+	got := make([]int, len(paid))
+	before := 0
+	for i, amount := range refunds {
+		shares := splitRefund(paid, before, before+amount)
+		if !slices.Equal(shares, wantShares[i]) {
+			t.Errorf("refund %d of %d: shares = %v, want %v", i+1, amount, shares, wantShares[i])
+		}
+		sum := 0
+		for j, s := range shares {
+			sum += s
+			got[j] += s
+		}
+		if sum != amount {
+			t.Errorf("refund %d: shares add up to %d, want %d", i+1, sum, amount)
+		}
+		before += amount
+	}
+	if !slices.Equal(got, paid) {
+		t.Errorf("fully refunded: payments got back %v, want %v", got, paid)
+	}
+
+	if shares := splitRefund(nil, 0, 5000); len(shares) != 0 {
+		t.Errorf("no payments: shares = %v, want none", shares)
+	}
+}
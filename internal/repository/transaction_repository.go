@@ -4,6 +4,12 @@ import "kasir-api/internal/domain"
 
 // TransactionRepository defines the interface for transaction data access.
 type TransactionRepository interface {
-	CreateTransaction(items []domain.CheckoutItem) (*domain.Transaction, error)
+	CreateTransaction(draft *domain.Transaction, useLock bool) (*domain.Transaction, error)
+	ListTransactions(f domain.TransactionFilter) ([]domain.Transaction, int, error)
+	GetTransactionByID(id int) (*domain.Transaction, error)
+	GetTransactionByReceiptNumber(number string) (*domain.Transaction, error)
+	GetTransactionByReceiptToken(token string) (*domain.Transaction, error)
+	VoidTransaction(id int, req domain.VoidRequest) (*domain.Refund, error)
+	RefundTransaction(id int, req domain.RefundRequest) (*domain.Refund, error)
 	GetSummaryHariIni() (*domain.SummaryHariIni, error)
-}
\ No newline at end of file
+}
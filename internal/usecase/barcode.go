@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+)
+
+// scanBarcodes returns the products behind the scanned codes, keyed by code. Codes matching one of
+// layouts are decoded as in-store scale labels and carry the quantity, and for price labels the
+// amount, they stand for. Unknown codes are skipped.
+func scanBarcodes(products repository.ProductRepository, layouts []domain.InStoreBarcodeLayout, codes []string) (map[string]domain.ScannedProduct, error) {
+	type label struct {
+		layout domain.InStoreBarcodeLayout
+		base   string
+		value  int
+	}
+	labels := make(map[string]label)
+	lookup := make([]string, 0, len(codes))
+	for _, code := range codes {
+		key := code
+		for _, l := range layouts {
+			if base, value, ok := l.Decode(code); ok {
+				labels[code] = label{layout: l, base: base, value: value}
+				key = base
+				break
+			}
+		}
+		lookup = append(lookup, key)
+	}
+
+	found, err := products.GetByBarcodes(lookup)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]domain.ScannedProduct, len(codes))
+	for _, code := range codes {
+		lb, isLabel := labels[code]
+		if !isLabel {
+			if p, ok := found[code]; ok {
+				out[code] = domain.ScannedProduct{Product: p}
+			}
+			continue
+		}
+		p, ok := found[lb.base]
+		if !ok {
+			continue
+		}
+		s := domain.ScannedProduct{Product: p}
+		switch lb.layout.Kind {
+		case domain.InStoreBarcodeWeight:
+			// Weighed products are priced per kg, so grams are thousandths of a unit.
+			s.Quantity = domain.Thousandths(lb.value)
+		case domain.InStoreBarcodePrice:
+			s.Amount = lb.value
+			s.Quantity = domain.Units(1)
+			if p.Harga > 0 {
+				// The quantity the price stands for, rounded to the nearest thousandth but never zero.
+				s.Quantity = max(domain.Thousandths((lb.value*1000+p.Harga/2)/p.Harga), domain.Thousandths(1))
+			}
+		}
+		out[code] = s
+	}
+	return out, nil
+}
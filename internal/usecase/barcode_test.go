@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"testing"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+)
+
+func TestScanScaleLabels(t *testing.T) {
+	layouts := []domain.InStoreBarcodeLayout{
+		{Prefix: "20", ItemDigits: 5, Kind: domain.InStoreBarcodeWeight},
+		{Prefix: "21", ItemDigits: 5, Kind: domain.InStoreBarcodePrice},
+	}
+	products := repository.NewProductMemoryRepo([]domain.Product{
+		{ID: 1, Nama: "Daging Sapi", Harga: 130000, Barcodes: []string{"2012345000001"}, Unit: "kg", Active: true},
+		{ID: 2, Nama: "Keju", Harga: 120000, Barcodes: []string{"2112345000008"}, Unit: "kg", Active: true},
+		{ID: 3, Nama: "Air Mineral", Harga: 4000, Barcodes: []string{"4006381333931"}, Unit: domain.DefaultUnit, Active: true},
+	})
+
+	found, err := scanBarcodes(products, layouts, []string{"2012345004535", "2112345015002", "4006381333931", "2054321004535"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 453 g of beef.
+	if s := found["2012345004535"]; s.ID != 1 || s.Quantity != domain.Thousandths(453) || s.Amount != 0 {
+		t.Errorf("weight label = product %d, quantity %s, amount %d; want product 1, 0.453, no amount", s.ID, s.Quantity, s.Amount)
+	}
+	// Rp 1.500 of cheese at Rp 120.000/kg is 12,5 g, rounded to 0.013 kg.
+	if s := found["2112345015002"]; s.ID != 2 || s.Quantity != domain.Thousandths(13) || s.Amount != 1500 {
+		t.Errorf("price label = product %d, quantity %s, amount %d; want product 2, 0.013, 1500", s.ID, s.Quantity, s.Amount)
+	}
+	if s := found["4006381333931"]; s.ID != 3 || s.Quantity != 0 || s.Amount != 0 {
+		t.Errorf("plain barcode = product %d, quantity %s, amount %d; want product 3 as it is", s.ID, s.Quantity, s.Amount)
+	}
+	if _, ok := found["2054321004535"]; ok {
+		t.Error("label of an unknown item was found")
+	}
+}
@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+)
+
+// ErrCartEmpty is returned when checking out a cart without items.
+var ErrCartEmpty = errors.New("cart has no items")
+
+// ErrPriceBarcodeInCart is returned when adding an item scanned from a price-embedded scale label to
+// a cart. Cart lines keep only a quantity, so the printed price would be lost.
+var ErrPriceBarcodeInCart = errors.New("price-embedded barcodes cannot be added to a cart; check them out directly")
+
+// CartUsecase holds business logic for parked carts.
+type CartUsecase struct {
+	repo         repository.CartRepository
+	transactions *TransactionUsecase
+	ttl          time.Duration
+	reserveStock bool
+}
+
+// NewCartUsecase creates a cart use case. Carts expire ttl after their last change; reserveStock is
+// the default for carts that do not say whether they reserve stock. Carts are checked out through
+// transactions, so they are priced exactly like POST /api/checkout.
+func NewCartUsecase(repo repository.CartRepository, transactions *TransactionUsecase, ttl time.Duration, reserveStock bool) *CartUsecase {
+	return &CartUsecase{
+		repo:         repo,
+		transactions: transactions,
+		ttl:          ttl,
+		reserveStock: reserveStock,
+	}
+}
+
+// Create parks a new cart. Returns *repository.ErrInsufficientStock if it reserves stock and some is short.
+func (u *CartUsecase) Create(req domain.CreateCartRequest) (*domain.Cart, error) {
+	c := domain.Cart{
+		ReserveStock: u.reserveStock,
+		Discount:     req.Discount,
+	}
+	if req.ReserveStock != nil {
+		c.ReserveStock = *req.ReserveStock
+	}
+	items, err := u.transactions.resolveItems(req.Items)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.transactions.resolveProducts(items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.Amount > 0 {
+			return nil, ErrPriceBarcodeInCart
+		}
+		c.Items = append(c.Items, domain.CartItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Unit:      item.Unit,
+			Modifiers: item.Modifiers,
+			Discount:  item.Discount,
+		})
+	}
+	return u.repo.Create(c, u.ttl)
+}
+
+// GetByID returns a cart with its items. Returns an error wrapping repository.ErrNotFound if not found.
+func (u *CartUsecase) GetByID(id int) (*domain.Cart, error) {
+	return u.repo.GetByID(id)
+}
+
+// AddItem adds item to an open cart, merging it with an existing line for the same product and unit.
+// The product may be given by barcode.
+func (u *CartUsecase) AddItem(cartID int, item domain.CheckoutItem) (*domain.Cart, error) {
+	items, err := u.transactions.resolveItems([]domain.CheckoutItem{item})
+	if err != nil {
+		return nil, err
+	}
+	if err := u.transactions.resolveProducts(items); err != nil {
+		return nil, err
+	}
+	if items[0].Amount > 0 {
+		return nil, ErrPriceBarcodeInCart
+	}
+	return u.repo.AddItem(cartID, items[0], u.ttl)
+}
+
+// UpdateItem sets the quantity and discount of a cart line.
+func (u *CartUsecase) UpdateItem(cartID, itemID int, item domain.CheckoutItem) (*domain.Cart, error) {
+	return u.repo.UpdateItem(cartID, itemID, item, u.ttl)
+}
+
+// RemoveItem deletes a cart line.
+func (u *CartUsecase) RemoveItem(cartID, itemID int) (*domain.Cart, error) {
+	return u.repo.RemoveItem(cartID, itemID, u.ttl)
+}
+
+// Cancel abandons an open cart, returning any reserved stock.
+func (u *CartUsecase) Cancel(id int) error {
+	return u.repo.Cancel(id)
+}
+
+// ExpireStale expires carts past their expiry and reports how many were expired.
+func (u *CartUsecase) ExpireStale() (int, error) {
+	return u.repo.ExpireStale()
+}
+
+// Checkout turns an open cart into a transaction paid with the given tenders, using the cart's
+// lines and discount. The cart is closed in the same database transaction as the sale; a cart
+// that is no longer open or has expired returns repository.ErrCartNotOpen.
+func (u *CartUsecase) Checkout(id int, req domain.CartCheckoutRequest, useLock bool) (*domain.Transaction, error) {
+	c, err := u.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != domain.CartStatusOpen {
+		return nil, repository.ErrCartNotOpen
+	}
+	if len(c.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+	checkout := domain.CheckoutRequest{
+		Discount: c.Discount,
+		Payment:  req.Payment,
+		Payments: req.Payments,
+	}
+	for _, it := range c.Items {
+		checkout.Items = append(checkout.Items, domain.CheckoutItem{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			Unit:      it.Unit,
+			Modifiers: it.Modifiers,
+			Discount:  it.Discount,
+		})
+	}
+	return u.transactions.checkout(checkout, id, "", useLock)
+}
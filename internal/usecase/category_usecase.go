@@ -1,10 +1,17 @@
 package usecase
 
 import (
+	"errors"
 	"kasir-api/internal/domain"
 	"kasir-api/internal/repository"
 )
 
+// ErrCategoryCycle is returned when a category would be placed under itself or one of its subcategories.
+var ErrCategoryCycle = repository.ErrCategoryCycle
+
+// ErrCategoryInUse is returned when deleting a category that products or subcategories still belong to.
+type ErrCategoryInUse = repository.ErrCategoryInUse
+
 // CategoryUsecase holds business logic for categories.
 type CategoryUsecase struct {
 	repo repository.CategoryRepository
@@ -25,17 +32,69 @@ func (u *CategoryUsecase) GetByID(id int) (*domain.Category, error) {
 	return u.repo.GetByID(id)
 }
 
+// Tree returns all categories nested under their parents.
+func (u *CategoryUsecase) Tree() ([]domain.CategoryNode, error) {
+	cats, err := u.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return domain.CategoryTree(cats), nil
+}
+
 // Create creates a new category. The repository assigns and returns the new ID.
+// Returns ErrCategoryNotFound if c.ParentID refers to a category that does not exist.
 func (u *CategoryUsecase) Create(c domain.Category) (domain.Category, error) {
+	if c.ParentID != nil {
+		if _, err := u.repo.GetByID(*c.ParentID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.Category{}, ErrCategoryNotFound
+			}
+			return domain.Category{}, err
+		}
+	}
 	return u.repo.Create(c)
 }
 
-// Update updates an existing category by ID.
+// Update updates an existing category by ID. Returns ErrCategoryNotFound if c.ParentID refers to a
+// category that does not exist, and ErrCategoryCycle if it is the category itself or one of its
+// subcategories. The repository checks for a cycle in the same transaction as the update.
 func (u *CategoryUsecase) Update(id int, c domain.Category) (domain.Category, error) {
+	if c.ParentID != nil {
+		if _, err := u.repo.GetByID(*c.ParentID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.Category{}, ErrCategoryNotFound
+			}
+			return domain.Category{}, err
+		}
+	}
 	return u.repo.Update(id, c)
 }
 
-// Delete deletes a category by ID.
-func (u *CategoryUsecase) Delete(id int) error {
+// Delete soft-deletes a category by ID. It can be brought back with Restore.
+// A category with subcategories is never deleted; Delete returns *ErrCategoryInUse.
+// If reassignTo is 0 and products still belong to the category, Delete returns *ErrCategoryInUse.
+// Otherwise all its products are first moved to category reassignTo, in the same transaction;
+// ErrCategoryNotFound is returned if that category does not exist. The repository checks what
+// still uses the category in the same transaction as the delete.
+func (u *CategoryUsecase) Delete(id, reassignTo int) error {
+	if reassignTo != 0 {
+		if _, err := u.repo.GetByID(reassignTo); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrCategoryNotFound
+			}
+			return err
+		}
+		return u.repo.ReassignAndDelete(id, reassignTo)
+	}
 	return u.repo.Delete(id)
 }
+
+// Restore undeletes a deleted category. Returns repository.ErrNotFound if no deleted category has that ID.
+func (u *CategoryUsecase) Restore(id int) error {
+	return u.repo.Restore(id)
+}
+
+// Purge permanently removes deleted categories that no product refers to.
+func (u *CategoryUsecase) Purge() (int, error) {
+	return u.repo.Purge()
+}
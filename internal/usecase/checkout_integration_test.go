@@ -0,0 +1,220 @@
+//go:build integration
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestCheckoutConcurrentStock fires more parallel checkouts for one product than it has stock,
+// with both checkout strategies, and checks that the final stock is exactly the starting stock
+// minus what was sold. It needs TEST_DATABASE_URL to point at a disposable database with all
+// migrations applied:
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration ./internal/usecase/
+func TestCheckoutConcurrentStock(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	uc, products, category := testCheckout(t, pool)
+
+	const (
+		stock     = 100
+		checkouts = 300
+	)
+	for _, useLock := range []bool{true, false} {
+		t.Run(fmt.Sprintf("lock=%v", useLock), func(t *testing.T) {
+			p, err := products.Create(domain.Product{
+				Nama:     fmt.Sprintf("Stress %d", time.Now().UnixNano()),
+				Harga:    1000,
+				Stok:     domain.Units(stock),
+				Unit:     domain.DefaultUnit,
+				Category: category,
+				Active:   true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := domain.CheckoutRequest{Items: []domain.CheckoutItem{{ProductID: p.ID, Quantity: domain.Units(1)}}}
+
+			var (
+				mu                   sync.Mutex
+				sold, short, retried int
+				failures             []error
+			)
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for range checkouts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					_, err := uc.Checkout(req, useLock)
+					var shortage *repository.ErrInsufficientStock
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						sold++
+					case errors.As(err, &shortage):
+						short++
+					case errors.Is(err, repository.ErrSerialization):
+						retried++
+					default:
+						failures = append(failures, err)
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			for _, err := range failures {
+				t.Errorf("checkout: %v", err)
+			}
+			var left domain.Quantity
+			if err := pool.QueryRow(ctx, "SELECT stok FROM products WHERE id = $1", p.ID).Scan(&left); err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("sold=%d short=%d out of retries=%d stock left=%s", sold, short, retried, left)
+			if want := domain.Units(stock - sold); left != want {
+				t.Errorf("stock left = %s, want %s after %d sales", left, want, sold)
+			}
+			if left < 0 {
+				t.Errorf("stock went negative: %s", left)
+			}
+			if useLock && sold != stock {
+				t.Errorf("sold %d with locking, want all %d in stock", sold, stock)
+			}
+		})
+	}
+}
+
+// TestCheckoutIdempotentStoresResponse checks that the transaction made for an Idempotency-Key is
+// stored with it by the checkout itself, so a replay returns it, receipt number included.
+func TestCheckoutIdempotentStoresResponse(t *testing.T) {
+	pool := testPool(t)
+	uc, products, category := testCheckout(t, pool)
+	p, err := products.Create(domain.Product{Nama: "Idempotent", Harga: 1000, Stok: domain.Units(10), Unit: domain.DefaultUnit, Category: category, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := domain.CheckoutRequest{Items: []domain.CheckoutItem{{ProductID: p.ID, Quantity: domain.Units(1)}}}
+
+	for _, useLock := range []bool{true, false} {
+		key := fmt.Sprintf("test-%d", time.Now().UnixNano())
+		tx, replayed, err := uc.CheckoutIdempotent(key, req, useLock)
+		if err != nil || replayed {
+			t.Fatalf("lock=%v: first checkout: replayed=%v err=%v", useLock, replayed, err)
+		}
+		again, replayed, err := uc.CheckoutIdempotent(key, req, useLock)
+		if err != nil || !replayed {
+			t.Fatalf("lock=%v: replay: replayed=%v err=%v", useLock, replayed, err)
+		}
+		if again.ID != tx.ID || again.ReceiptNumber == "" || again.ReceiptNumber != tx.ReceiptNumber {
+			t.Errorf("lock=%v: replay = id %d receipt %q, want id %d receipt %q", useLock, again.ID, again.ReceiptNumber, tx.ID, tx.ReceiptNumber)
+		}
+	}
+}
+
+// testPool connects to TEST_DATABASE_URL, or skips the test if it is not set.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// testCheckout returns a transaction use case on pool, with receipt numbers of their own, and a
+// fresh category to create test products in.
+func testCheckout(t *testing.T, pool *pgxpool.Pool) (*TransactionUsecase, *repository.ProductPG, domain.Category) {
+	t.Helper()
+	category, err := repository.NewCategoryPG(pool).Create(domain.Category{Nama: fmt.Sprintf("Test %d", time.Now().UnixNano())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	products := repository.NewProductPG(pool)
+	numbering := domain.ReceiptNumbering{Pattern: "{OUTLET}/{YYYY}{MM}{DD}/{SEQ:6}", Reset: domain.ReceiptResetDaily, Outlet: "TEST"}
+	uc := NewTransactionUsecase(repository.NewTransactionPG(pool, numbering), products, repository.NewIdempotencyPG(pool), nil, nil,
+		TransactionOptions{MaxRetries: 50, IdempotencyTTL: time.Hour})
+	return uc, products, category
+}
+
+// TestSummaryNetsRefunds checks that a partial refund comes off the day's discount and the totals
+// of the methods its sale was paid with, keeping the method totals in step with revenue.
+func TestSummaryNetsRefunds(t *testing.T) {
+	pool := testPool(t)
+	uc, products, category := testCheckout(t, pool)
+	report := repository.NewTransactionPG(pool, domain.ReceiptNumbering{})
+	p, err := products.Create(domain.Product{Nama: "Summary", Harga: 10000, Stok: domain.Units(10), Unit: domain.DefaultUnit, Category: category, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := report.GetSummaryHariIni()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 x 10.000 less 10% is 27.000, paid 20.000 by QRIS and 7.000 in cash. Refunding one unit
+	// gives back 9.000: 6.666 to QRIS and the 2.334 rest to cash, and 1.000 of the discount.
+	tx, err := uc.Checkout(domain.CheckoutRequest{
+		Items: []domain.CheckoutItem{{ProductID: p.ID, Quantity: domain.Units(3),
+			Discount: &domain.Discount{Type: domain.DiscountTypePercent, Value: 10}}},
+		Payments: []domain.PaymentInput{{Method: domain.PaymentMethodQRIS, Amount: 20000}, {Method: domain.PaymentMethodCash, Amount: 7000}},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.Refund(tx.ID, domain.RefundRequest{Reason: "test", PerformedBy: "test",
+		Items: []domain.RefundLine{{DetailID: tx.Details[0].ID, Quantity: domain.Units(1)}}}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := report.GetSummaryHariIni()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byMethod := func(s *domain.SummaryHariIni) map[string]int {
+		out := make(map[string]int)
+		for _, pt := range s.PerMetode {
+			out[pt.Method] = pt.Total
+		}
+		return out
+	}
+	b, a := byMethod(before), byMethod(after)
+	if got := a[domain.PaymentMethodQRIS] - b[domain.PaymentMethodQRIS]; got != 13334 {
+		t.Errorf("qris total grew by %d, want 13334", got)
+	}
+	if got := a[domain.PaymentMethodCash] - b[domain.PaymentMethodCash]; got != 4666 {
+		t.Errorf("cash total grew by %d, want 4666", got)
+	}
+	collected := func(s *domain.SummaryHariIni) int { return s.TotalRevenue + s.TotalPajak + s.TotalServiceCharge }
+	if got := collected(after) - collected(before); got != 18000 {
+		t.Errorf("revenue, tax and service charge grew by %d, want 18000", got)
+	}
+	if got := after.TotalDiskon - before.TotalDiskon; got != 2000 {
+		t.Errorf("discount grew by %d, want 2000", got)
+	}
+}
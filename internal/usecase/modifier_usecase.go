@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+)
+
+// ErrInvalidModifierGroup is returned (wrapped with details) when a modifier group is malformed or
+// attached to a product or category that does not exist.
+var ErrInvalidModifierGroup = errors.New("invalid modifier group")
+
+// ModifierUsecase holds business logic for modifier groups.
+type ModifierUsecase struct {
+	repo         repository.ModifierRepository
+	productRepo  repository.ProductRepository
+	categoryRepo repository.CategoryRepository
+}
+
+// NewModifierUsecase creates a new modifier group use case.
+func NewModifierUsecase(repo repository.ModifierRepository, productRepo repository.ProductRepository, categoryRepo repository.CategoryRepository) *ModifierUsecase {
+	return &ModifierUsecase{repo: repo, productRepo: productRepo, categoryRepo: categoryRepo}
+}
+
+// GetAll returns all modifier groups.
+func (u *ModifierUsecase) GetAll() ([]domain.ModifierGroup, error) {
+	return u.repo.GetAll()
+}
+
+// GetByID returns a modifier group by ID. Returns repository.ErrNotFound if not found.
+func (u *ModifierUsecase) GetByID(id int) (*domain.ModifierGroup, error) {
+	return u.repo.GetByID(id)
+}
+
+// Create creates a modifier group with its options. Returns ErrInvalidModifierGroup if it is malformed.
+func (u *ModifierUsecase) Create(g domain.ModifierGroup) (domain.ModifierGroup, error) {
+	if err := u.normalize(&g, nil); err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	return u.repo.Create(g)
+}
+
+// Update replaces a modifier group. Options keep their ID when it is sent back; options without an
+// ID are added and options left out are removed. Returns repository.ErrNotFound if the group does
+// not exist and ErrInvalidModifierGroup if it is malformed or an option ID is not one of its own.
+func (u *ModifierUsecase) Update(id int, g domain.ModifierGroup) (domain.ModifierGroup, error) {
+	current, err := u.repo.GetByID(id)
+	if err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	if err := u.normalize(&g, current.Options); err != nil {
+		return domain.ModifierGroup{}, err
+	}
+	return u.repo.Update(id, g)
+}
+
+// Delete removes a modifier group and its options. Returns repository.ErrNotFound if not found.
+func (u *ModifierUsecase) Delete(id int) error {
+	return u.repo.Delete(id)
+}
+
+// normalize trims and validates g. A group needs a name and at least one option; MinSelect may not
+// be negative or exceed the number of options, or MaxSelect when that is set. Option names must be
+// distinct and existing option IDs must be among current. Products and categories are deduplicated
+// and must exist.
+func (u *ModifierUsecase) normalize(g *domain.ModifierGroup, current []domain.Modifier) error {
+	g.Nama = strings.TrimSpace(g.Nama)
+	if g.Nama == "" {
+		return fmt.Errorf("%w: nama is required", ErrInvalidModifierGroup)
+	}
+	if len(g.Options) == 0 {
+		return fmt.Errorf("%w: at least one option is required", ErrInvalidModifierGroup)
+	}
+	if g.MinSelect < 0 || g.MaxSelect < 0 {
+		return fmt.Errorf("%w: min_select and max_select must not be negative", ErrInvalidModifierGroup)
+	}
+	if g.MaxSelect > 0 && g.MinSelect > g.MaxSelect {
+		return fmt.Errorf("%w: min_select %d exceeds max_select %d", ErrInvalidModifierGroup, g.MinSelect, g.MaxSelect)
+	}
+	if g.MinSelect > len(g.Options) {
+		return fmt.Errorf("%w: min_select %d exceeds the %d option(s)", ErrInvalidModifierGroup, g.MinSelect, len(g.Options))
+	}
+	names := make(map[string]bool, len(g.Options))
+	for i := range g.Options {
+		m := &g.Options[i]
+		m.Nama = strings.TrimSpace(m.Nama)
+		if m.Nama == "" {
+			return fmt.Errorf("%w: option nama is required", ErrInvalidModifierGroup)
+		}
+		key := strings.ToLower(m.Nama)
+		if names[key] {
+			return fmt.Errorf("%w: option %q is listed twice", ErrInvalidModifierGroup, m.Nama)
+		}
+		names[key] = true
+		if m.ID != 0 && !slices.ContainsFunc(current, func(c domain.Modifier) bool { return c.ID == m.ID }) {
+			return fmt.Errorf("%w: option id %d does not belong to this group", ErrInvalidModifierGroup, m.ID)
+		}
+	}
+
+	slices.Sort(g.ProductIDs)
+	g.ProductIDs = slices.Compact(g.ProductIDs)
+	slices.Sort(g.CategoryIDs)
+	g.CategoryIDs = slices.Compact(g.CategoryIDs)
+	if g.ProductIDs == nil {
+		g.ProductIDs = []int{}
+	}
+	if g.CategoryIDs == nil {
+		g.CategoryIDs = []int{}
+	}
+	if len(g.ProductIDs) > 0 {
+		products, err := u.productRepo.GetByIDs(g.ProductIDs)
+		if err != nil {
+			return err
+		}
+		for _, id := range g.ProductIDs {
+			if !slices.ContainsFunc(products, func(p domain.Product) bool { return p.ID == id }) {
+				return fmt.Errorf("%w: product id %d not found", ErrInvalidModifierGroup, id)
+			}
+		}
+	}
+	if len(g.CategoryIDs) > 0 {
+		cats, err := u.categoryRepo.GetAll()
+		if err != nil {
+			return err
+		}
+		for _, id := range g.CategoryIDs {
+			if !slices.ContainsFunc(cats, func(c domain.Category) bool { return c.ID == id }) {
+				return fmt.Errorf("%w: category id %d not found", ErrInvalidModifierGroup, id)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,519 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+)
+
+// ErrInvalidPayment is returned (wrapped with details) when the payment does not settle the total.
+var ErrInvalidPayment = errors.New("invalid payment")
+
+// ErrProductInactive is returned (wrapped with the product) when checking out a product that is not active.
+var ErrProductInactive = errors.New("product is not active")
+
+// ErrVariantRequired is returned (wrapped with the product) when selling a product that is sold
+// only through its variants.
+var ErrVariantRequired = errors.New("product has variants; sell one of its variants")
+
+// ErrInvalidModifier is returned (wrapped with details) when the modifiers picked for a line are
+// not offered for its product or break a group's minimum or maximum.
+var ErrInvalidModifier = errors.New("invalid modifier")
+
+// ErrInvalidDiscount is returned (wrapped with details) when a discount is malformed or exceeds its amount.
+var ErrInvalidDiscount = errors.New("invalid discount")
+
+// price builds an unsaved transaction for req from current product prices, applying line
+// discounts, then the cart discount, then tax and service charge. Lines sold in another unit than
+// the base unit take that unit's price, and the price of the line's modifiers is added to it; bundle
+// lines record the stock they take from each component. Returns an error wrapping
+// repository.ErrNotFound if a product does not exist, ErrVariantRequired if it has variants,
+// ErrInvalidUnit if it is not sold in the requested unit, ErrInvalidModifier if its modifiers are
+// not valid, or ErrInvalidDiscount if a discount is malformed or too large. The products that were
+// priced are returned by ID.
+func (u *TransactionUsecase) price(req domain.CheckoutRequest) (*domain.Transaction, map[int]domain.Product, error) {
+	items, err := u.resolveItems(req.Items)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]int, 0, len(items))
+	seen := make(map[int]bool, len(items))
+	for _, item := range items {
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			ids = append(ids, item.ProductID)
+		}
+	}
+	products, err := u.products.GetByIDs(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[int]domain.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	categories, err := u.categoryChains(byID)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups, err := u.modifierGroups(byID, categories)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx := &domain.Transaction{Details: make([]domain.TransactionDetail, 0, len(items))}
+	for _, item := range items {
+		p, ok := byID[item.ProductID]
+		if !ok {
+			return nil, nil, fmt.Errorf("product id %d %w", item.ProductID, repository.ErrNotFound)
+		}
+		if p.HasVariants() {
+			return nil, nil, fmt.Errorf("product id %d: %w", p.ID, ErrVariantRequired)
+		}
+		unit, ok := p.SellingUnit(item.Unit)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: product id %d is not sold per %s", ErrInvalidUnit, p.ID, item.Unit)
+		}
+		modifiers, err := selectModifiers(p, categories[p.Category.ID], item.Modifiers, groups)
+		if err != nil {
+			return nil, nil, err
+		}
+		unitPrice := unit.Harga
+		for _, m := range modifiers {
+			unitPrice += m.Harga
+		}
+		if unitPrice < 0 {
+			return nil, nil, fmt.Errorf("%w: modifiers take the price of product id %d below zero", ErrInvalidModifier, p.ID)
+		}
+		gross := item.Quantity.Price(unitPrice)
+		if item.Amount > 0 {
+			if len(modifiers) > 0 {
+				return nil, nil, fmt.Errorf("%w: product id %d is priced by its label and takes no modifiers", ErrInvalidModifier, p.ID)
+			}
+			gross = item.Amount
+		}
+		discount, err := discountAmount(item.Discount, gross)
+		if err != nil {
+			return nil, nil, fmt.Errorf("product id %d: %w", item.ProductID, err)
+		}
+		detail := domain.TransactionDetail{
+			ProductID:      p.ID,
+			ProductName:    p.Nama,
+			UnitPrice:      unitPrice,
+			UnitCost:       unit.Factor.Price(p.HargaPokok),
+			Quantity:       item.Quantity,
+			Unit:           unit.Name,
+			UnitFactor:     unit.Factor,
+			GrossAmount:    gross,
+			DiscountAmount: discount,
+			Subtotal:       gross - discount,
+			Modifiers:      modifiers,
+		}
+		for _, c := range p.Components {
+			c.Quantity = detail.BaseQuantity().Mul(c.Quantity)
+			detail.Components = append(detail.Components, c)
+		}
+		tx.Details = append(tx.Details, detail)
+	}
+
+	net := 0
+	for _, d := range tx.Details {
+		net += d.Subtotal
+	}
+	cartDiscount, err := discountAmount(req.Discount, net)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cart: %w", err)
+	}
+	allocate(tx.Details, cartDiscount)
+
+	tx.CartDiscountAmount = cartDiscount
+	taxable := 0
+	for _, d := range tx.Details {
+		tx.GrossAmount += d.GrossAmount
+		tx.DiscountAmount += d.DiscountAmount
+		if !u.opts.Tax.exempt(categories[byID[d.ProductID].Category.ID]) {
+			taxable += d.Subtotal
+		}
+	}
+	applyTax(tx, u.opts.Tax, taxable)
+	return tx, byID, nil
+}
+
+// resolveItems returns a copy of items in which lines given by barcode carry the product ID of
+// that barcode. A missing quantity counts as 1; for scale labels it is multiplied by the weight or
+// quantity on the label, and price labels also set Amount. A unit naming the product's base unit
+// is cleared, so the same product in the same unit is always spelled alike. Returns an error
+// wrapping repository.ErrNotFound for an unknown barcode or product, or ErrInvalidUnit for a unit
+// the product is not sold in.
+func (u *TransactionUsecase) resolveItems(items []domain.CheckoutItem) ([]domain.CheckoutItem, error) {
+	var codes []string
+	hasUnits := false
+	for _, item := range items {
+		if item.ProductID == 0 && item.Barcode != "" && !slices.Contains(codes, item.Barcode) {
+			codes = append(codes, item.Barcode)
+		}
+		hasUnits = hasUnits || item.Unit != ""
+	}
+	if len(codes) == 0 && !hasUnits {
+		return items, nil
+	}
+	found := map[string]domain.ScannedProduct{}
+	if len(codes) > 0 {
+		var err error
+		if found, err = scanBarcodes(u.products, u.opts.InStoreBarcodes, codes); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]domain.CheckoutItem, len(items))
+	for i, item := range items {
+		if item.ProductID == 0 && item.Barcode != "" {
+			s, ok := found[item.Barcode]
+			if !ok {
+				return nil, fmt.Errorf("barcode %s %w", item.Barcode, repository.ErrNotFound)
+			}
+			item.ProductID = s.ID
+			if item.Quantity == 0 {
+				item.Quantity = domain.Units(1)
+			}
+			if s.Amount > 0 {
+				item.Amount = item.Quantity.Price(s.Amount)
+			}
+			if s.Quantity > 0 {
+				item.Quantity = item.Quantity.Mul(s.Quantity)
+			}
+		}
+		out[i] = item
+	}
+	if hasUnits {
+		if err := u.resolveProducts(out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// resolveProducts checks that the product of every line exists and can be sold by itself, that a
+// line with a unit names one the product is sold in, and that its modifiers are valid. Units naming
+// the base unit are cleared and modifiers are sorted. price checks the same; carts call this to
+// reject lines before reserving stock for them.
+func (u *TransactionUsecase) resolveProducts(items []domain.CheckoutItem) error {
+	var ids []int
+	for _, item := range items {
+		if !slices.Contains(ids, item.ProductID) {
+			ids = append(ids, item.ProductID)
+		}
+	}
+	products, err := u.products.GetByIDs(ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]domain.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	categories, err := u.categoryChains(byID)
+	if err != nil {
+		return err
+	}
+	groups, err := u.modifierGroups(byID, categories)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		item := &items[i]
+		p, ok := byID[item.ProductID]
+		if !ok {
+			return fmt.Errorf("product id %d %w", item.ProductID, repository.ErrNotFound)
+		}
+		if p.HasVariants() {
+			return fmt.Errorf("product id %d: %w", p.ID, ErrVariantRequired)
+		}
+		if _, err := selectModifiers(p, categories[p.Category.ID], item.Modifiers, groups); err != nil {
+			return err
+		}
+		item.Modifiers = slices.Sorted(slices.Values(item.Modifiers))
+		if item.Unit == "" {
+			continue
+		}
+		if _, ok := p.SellingUnit(item.Unit); !ok {
+			return fmt.Errorf("%w: product id %d is not sold per %s", ErrInvalidUnit, p.ID, item.Unit)
+		}
+		if item.Unit == p.Unit {
+			item.Unit = ""
+		}
+	}
+	return nil
+}
+
+// categoryChains returns, for the category of each of products, that category and the categories
+// above it, since modifier groups and tax exemptions of a category also apply to its
+// subcategories. Categories are only loaded when modifiers or tax exemptions need them; otherwise
+// each chain is just the product's own category.
+func (u *TransactionUsecase) categoryChains(products map[int]domain.Product) (map[int][]int, error) {
+	chains := make(map[int][]int)
+	if u.categories == nil || (u.modifiers == nil && len(u.opts.Tax.ExemptCategories) == 0) {
+		for _, p := range products {
+			chains[p.Category.ID] = []int{p.Category.ID}
+		}
+		return chains, nil
+	}
+	cats, err := u.categories.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		if _, ok := chains[p.Category.ID]; !ok {
+			chains[p.Category.ID] = domain.CategoryAncestors(cats, p.Category.ID)
+		}
+	}
+	return chains, nil
+}
+
+// modifierGroups returns the modifier groups that apply to any of products, given the category
+// chains of products from categoryChains.
+func (u *TransactionUsecase) modifierGroups(products map[int]domain.Product, categories map[int][]int) ([]domain.ModifierGroup, error) {
+	if u.modifiers == nil || len(products) == 0 {
+		return nil, nil
+	}
+	var productIDs, categoryIDs []int
+	for _, p := range products {
+		productIDs = append(productIDs, p.ID)
+		if p.ParentID != nil {
+			productIDs = append(productIDs, *p.ParentID)
+		}
+	}
+	for _, chain := range categories {
+		for _, id := range chain {
+			if !slices.Contains(categoryIDs, id) {
+				categoryIDs = append(categoryIDs, id)
+			}
+		}
+	}
+	return u.modifiers.GetApplicable(productIDs, categoryIDs)
+}
+
+// selectModifiers checks the modifier IDs picked for a line of p against the groups that apply to
+// it and returns them in group and option order. categoryIDs are p's category and the categories
+// above it. Every picked modifier must belong to one of those groups and be picked once, and each
+// group must get between its MinSelect and MaxSelect picks. Returns an error wrapping
+// ErrInvalidModifier otherwise.
+func selectModifiers(p domain.Product, categoryIDs, picked []int, groups []domain.ModifierGroup) ([]domain.DetailModifier, error) {
+	var applicable []domain.ModifierGroup
+	for _, g := range groups {
+		if g.AppliesTo(p, categoryIDs) {
+			applicable = append(applicable, g)
+		}
+	}
+	for i, id := range picked {
+		if slices.Contains(picked[:i], id) {
+			return nil, fmt.Errorf("%w: modifier id %d is picked twice for product id %d", ErrInvalidModifier, id, p.ID)
+		}
+		offered := slices.ContainsFunc(applicable, func(g domain.ModifierGroup) bool {
+			return slices.ContainsFunc(g.Options, func(m domain.Modifier) bool { return m.ID == id })
+		})
+		if !offered {
+			return nil, fmt.Errorf("%w: modifier id %d is not offered for product id %d", ErrInvalidModifier, id, p.ID)
+		}
+	}
+	var out []domain.DetailModifier
+	for _, g := range applicable {
+		n := 0
+		for _, m := range g.Options {
+			if slices.Contains(picked, m.ID) {
+				n++
+				out = append(out, domain.DetailModifier{ModifierID: m.ID, Group: g.Nama, Nama: m.Nama, Harga: m.Harga})
+			}
+		}
+		if n < g.MinSelect {
+			return nil, fmt.Errorf("%w: product id %d needs at least %d %s", ErrInvalidModifier, p.ID, g.MinSelect, g.Nama)
+		}
+		if g.MaxSelect > 0 && n > g.MaxSelect {
+			return nil, fmt.Errorf("%w: product id %d takes at most %d %s", ErrInvalidModifier, p.ID, g.MaxSelect, g.Nama)
+		}
+	}
+	return out, nil
+}
+
+// discountAmount validates d and returns its rupiah amount on base, which it may not exceed.
+// A nil discount is zero.
+func discountAmount(d *domain.Discount, base int) (int, error) {
+	if d == nil {
+		return 0, nil
+	}
+	switch d.Type {
+	case domain.DiscountTypePercent:
+		if d.Value < 0 || d.Value > 100 {
+			return 0, fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidDiscount)
+		}
+	case domain.DiscountTypeFixed:
+		if d.Value < 0 {
+			return 0, fmt.Errorf("%w: amount must not be negative", ErrInvalidDiscount)
+		}
+	default:
+		return 0, fmt.Errorf("%w: type must be percent or fixed", ErrInvalidDiscount)
+	}
+	amount := d.Amount(base)
+	if amount > base {
+		return 0, fmt.Errorf("%w: discount %d exceeds amount %d", ErrInvalidDiscount, amount, base)
+	}
+	return amount, nil
+}
+
+// allocate spreads a cart-level discount over lines in proportion to their subtotals, adding each
+// share to the line's DiscountAmount and reducing its Subtotal. Rounding leftovers go to the last
+// lines so the shares add up exactly and no subtotal goes negative.
+func allocate(lines []domain.TransactionDetail, discount int) {
+	if discount == 0 {
+		return
+	}
+	net := 0
+	for _, l := range lines {
+		net += l.Subtotal
+	}
+	given, cumulative := 0, 0
+	for i := range lines {
+		cumulative += lines[i].Subtotal
+		// Share of the discount up to and including this line, so rounding never accumulates.
+		share := discount*cumulative/net - given
+		given += share
+		lines[i].DiscountAmount += share
+		lines[i].Subtotal -= share
+	}
+}
+
+// applyPayments checks that tenders settle tx.TotalAmount and records the payments and change on tx.
+// The tenders must add up to the total; only cash may push the sum over it, and the excess, which
+// cannot be more than the cash tendered, is handed back as change. A single non-cash tender without
+// an amount settles the whole total. No tenders records nothing.
+func applyPayments(tx *domain.Transaction, tenders []domain.PaymentInput) error {
+	if len(tenders) == 0 {
+		return nil
+	}
+	payments := make([]domain.Payment, 0, len(tenders))
+	sum, cash := 0, 0
+	for _, t := range tenders {
+		if !domain.ValidPaymentMethod(t.Method) {
+			return fmt.Errorf("%w: unknown method %q", ErrInvalidPayment, t.Method)
+		}
+		amount := t.Amount
+		if amount == 0 && len(tenders) == 1 && t.Method != domain.PaymentMethodCash {
+			amount = tx.TotalAmount
+		}
+		if amount <= 0 {
+			return fmt.Errorf("%w: %s amount must be greater than 0", ErrInvalidPayment, t.Method)
+		}
+		if t.Method == domain.PaymentMethodCash {
+			cash += amount
+		}
+		sum += amount
+		payments = append(payments, domain.Payment{Method: t.Method, Amount: amount, Tendered: amount})
+	}
+
+	if sum < tx.TotalAmount {
+		return fmt.Errorf("%w: payments total %d does not cover total %d", ErrInvalidPayment, sum, tx.TotalAmount)
+	}
+	change := sum - tx.TotalAmount
+	if change > cash {
+		return fmt.Errorf("%w: non-cash payments total %d exceed total %d; only cash can be overpaid",
+			ErrInvalidPayment, sum-cash, tx.TotalAmount)
+	}
+
+	// Hand the change back from the last cash tenders first.
+	left := change
+	for i := len(payments) - 1; i >= 0 && left > 0; i-- {
+		if payments[i].Method != domain.PaymentMethodCash {
+			continue
+		}
+		c := min(left, payments[i].Tendered)
+		payments[i].Change = c
+		payments[i].Amount -= c
+		left -= c
+	}
+	tx.Change = change
+	tx.Payments = payments
+	return nil
+}
+
+// Quote prices req exactly like Checkout and validates its tenders, if any, but saves nothing and
+// takes no locks. Instead of failing on stock, it reports products that are short, would drop to
+// opts.LowStockThreshold or below, or are inactive as warnings; stock may still change before the
+// actual checkout. For bundles the stock warnings are about their components.
+func (u *TransactionUsecase) Quote(req domain.CheckoutRequest) (*domain.CheckoutQuote, error) {
+	draft, products, err := u.price(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPayments(draft, req.Tenders()); err != nil {
+		return nil, err
+	}
+
+	warnings := []domain.QuoteWarning{}
+	requested := make(map[int]domain.Quantity)
+	inactive := make(map[int]bool)
+	var ids, components []int
+	for _, d := range draft.Details {
+		if p := products[d.ProductID]; !p.Active && !inactive[p.ID] {
+			inactive[p.ID] = true
+			warnings = append(warnings, domain.QuoteWarning{
+				Code:      domain.QuoteWarningInactive,
+				ProductID: p.ID,
+				Message:   fmt.Sprintf("%s is not active", p.Nama),
+			})
+		}
+		for _, use := range d.StockUsage() {
+			if _, ok := requested[use.ProductID]; !ok {
+				ids = append(ids, use.ProductID)
+				if _, ok := products[use.ProductID]; !ok {
+					components = append(components, use.ProductID)
+				}
+			}
+			requested[use.ProductID] += use.Quantity
+		}
+	}
+	if len(components) > 0 {
+		found, err := u.products.GetByIDs(components)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range found {
+			products[p.ID] = p
+		}
+	}
+	for _, id := range ids {
+		p := products[id]
+		switch left := p.Stok - requested[id]; {
+		case left < 0:
+			warnings = append(warnings, domain.QuoteWarning{
+				Code:      domain.QuoteWarningInsufficientStock,
+				ProductID: id,
+				Message:   fmt.Sprintf("%s: requested %s, only %s in stock", p.Nama, requested[id], p.Stok),
+			})
+		case left <= domain.Units(u.opts.LowStockThreshold):
+			warnings = append(warnings, domain.QuoteWarning{
+				Code:      domain.QuoteWarningLowStock,
+				ProductID: id,
+				Message:   fmt.Sprintf("%s: %s left in stock after this sale", p.Nama, left),
+			})
+		}
+	}
+
+	return &domain.CheckoutQuote{
+		GrossAmount:         draft.GrossAmount,
+		DiscountAmount:      draft.DiscountAmount,
+		CartDiscountAmount:  draft.CartDiscountAmount,
+		NetAmount:           draft.NetAmount,
+		ServiceChargeAmount: draft.ServiceChargeAmount,
+		TaxAmount:           draft.TaxAmount,
+		TotalAmount:         draft.TotalAmount,
+		TaxRate:             draft.TaxRate,
+		TaxInclusive:        draft.TaxInclusive,
+		ServiceChargeRate:   draft.ServiceChargeRate,
+		Change:              draft.Change,
+		Details:             draft.Details,
+		Payments:            draft.Payments,
+		Warnings:            warnings,
+	}, nil
+}
@@ -0,0 +1,210 @@
+package usecase
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"kasir-api/internal/domain"
+	"kasir-api/internal/repository"
+)
+
+// fakeModifiers serves GetApplicable from a fixed list of groups, matching IDs like ModifierPG.
+type fakeModifiers struct {
+	repository.ModifierRepository
+	groups []domain.ModifierGroup
+}
+
+func (f fakeModifiers) GetApplicable(productIDs, categoryIDs []int) ([]domain.ModifierGroup, error) {
+	var out []domain.ModifierGroup
+	for _, g := range f.groups {
+		in := func(id int) bool { return slices.Contains(productIDs, id) }
+		if slices.ContainsFunc(g.ProductIDs, in) || slices.ContainsFunc(g.CategoryIDs, func(id int) bool { return slices.Contains(categoryIDs, id) }) {
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+func TestModifierGroupOfParentCategory(t *testing.T) {
+	minuman, kopi := 1, 2
+	categories := repository.NewCategoryMemoryRepo([]domain.Category{
+		{ID: minuman, Nama: "Minuman"},
+		{ID: kopi, Nama: "Kopi", ParentID: &minuman},
+		{ID: 3, Nama: "Espresso-based", ParentID: &kopi},
+		{ID: 4, Nama: "Makanan"},
+	}, nil)
+	products := repository.NewProductMemoryRepo([]domain.Product{
+		{ID: 1, Nama: "Americano", Harga: 20000, Stok: domain.Units(10), Unit: domain.DefaultUnit, Active: true, Category: domain.Category{ID: 3}},
+		{ID: 2, Nama: "Roti Bakar", Harga: 15000, Stok: domain.Units(10), Unit: domain.DefaultUnit, Active: true, Category: domain.Category{ID: 4}},
+	})
+	modifiers := fakeModifiers{groups: []domain.ModifierGroup{{
+		ID: 1, Nama: "Shot", MinSelect: 1, CategoryIDs: []int{minuman},
+		Options: []domain.Modifier{{ID: 10, Nama: "Extra shot", Harga: 5000}},
+	}}}
+	uc := NewTransactionUsecase(nil, products, nil, modifiers, categories, TransactionOptions{})
+
+	quote, err := uc.Quote(domain.CheckoutRequest{Items: []domain.CheckoutItem{{ProductID: 1, Quantity: domain.Units(1), Modifiers: []int{10}}}})
+	if err != nil {
+		t.Fatalf("modifier of the grandparent category: %v", err)
+	}
+	if got := quote.Details[0].UnitPrice; got != 25000 {
+		t.Errorf("unit price = %d, want 25000 with the extra shot", got)
+	}
+
+	_, err = uc.Quote(domain.CheckoutRequest{Items: []domain.CheckoutItem{{ProductID: 1, Quantity: domain.Units(1)}}})
+	if !errors.Is(err, ErrInvalidModifier) {
+		t.Errorf("required group of the grandparent category left out: err = %v, want ErrInvalidModifier", err)
+	}
+	_, err = uc.Quote(domain.CheckoutRequest{Items: []domain.CheckoutItem{{ProductID: 2, Quantity: domain.Units(1), Modifiers: []int{10}}}})
+	if !errors.Is(err, ErrInvalidModifier) {
+		t.Errorf("modifier on a product of another category: err = %v, want ErrInvalidModifier", err)
+	}
+}
+
+func TestTaxExemptParentCategory(t *testing.T) {
+	sembako, beras := 1, 2
+	categories := repository.NewCategoryMemoryRepo([]domain.Category{
+		{ID: sembako, Nama: "Sembako"},
+		{ID: beras, Nama: "Beras", ParentID: &sembako},
+		{ID: 3, Nama: "Premium", ParentID: &beras},
+		{ID: 4, Nama: "Kebersihan"},
+	}, nil)
+	products := repository.NewProductMemoryRepo([]domain.Product{
+		{ID: 1, Nama: "Beras Pandan Wangi", Harga: 70000, Stok: domain.Units(10), Unit: domain.DefaultUnit, Active: true, Category: domain.Category{ID: 3}},
+		{ID: 2, Nama: "Sabun", Harga: 10000, Stok: domain.Units(10), Unit: domain.DefaultUnit, Active: true, Category: domain.Category{ID: 4}},
+	})
+	uc := NewTransactionUsecase(nil, products, nil, nil, categories,
+		TransactionOptions{Tax: TaxRules{Rate: 11, ExemptCategories: []int{sembako}}})
+
+	quote, err := uc.Quote(domain.CheckoutRequest{Items: []domain.CheckoutItem{
+		{ProductID: 1, Quantity: domain.Units(1)},
+		{ProductID: 2, Quantity: domain.Units(1)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.TaxAmount != 1100 {
+		t.Errorf("tax = %d, want 1100 on the soap only, as rice is under an exempt category", quote.TaxAmount)
+	}
+}
+
+func TestApplyPayments(t *testing.T) {
+	const total = 48252
+	cash, qris := domain.PaymentMethodCash, domain.PaymentMethodQRIS
+	tests := []struct {
+		name       string
+		tenders    []domain.PaymentInput
+		wantErr    bool
+		wantChange int
+		want       []domain.Payment
+	}{
+		{name: "no tenders"},
+		{
+			name:       "cash overpaid",
+			tenders:    []domain.PaymentInput{{Method: cash, Amount: 50000}},
+			wantChange: 1748,
+			want:       []domain.Payment{{Method: cash, Amount: total, Tendered: 50000, Change: 1748}},
+		},
+		{
+			name:    "single non-cash tender without amount",
+			tenders: []domain.PaymentInput{{Method: qris}},
+			want:    []domain.Payment{{Method: qris, Amount: total, Tendered: total}},
+		},
+		{
+			name:       "split, change from the cash",
+			tenders:    []domain.PaymentInput{{Method: qris, Amount: 20000}, {Method: cash, Amount: 30000}},
+			wantChange: 1748,
+			want: []domain.Payment{
+				{Method: qris, Amount: 20000, Tendered: 20000},
+				{Method: cash, Amount: 28252, Tendered: 30000, Change: 1748},
+			},
+		},
+		{
+			name:       "change from the last cash tender first",
+			tenders:    []domain.PaymentInput{{Method: cash, Amount: 40000}, {Method: cash, Amount: 10000}},
+			wantChange: 1748,
+			want: []domain.Payment{
+				{Method: cash, Amount: 40000, Tendered: 40000},
+				{Method: cash, Amount: 8252, Tendered: 10000, Change: 1748},
+			},
+		},
+		{name: "short", tenders: []domain.PaymentInput{{Method: cash, Amount: 48000}}, wantErr: true},
+		{name: "non-cash overpaid", tenders: []domain.PaymentInput{{Method: qris, Amount: 50000}}, wantErr: true},
+		{name: "change above the cash", tenders: []domain.PaymentInput{{Method: qris, Amount: 49000}, {Method: cash, Amount: 500}}, wantErr: true},
+		{name: "cash without amount", tenders: []domain.PaymentInput{{Method: cash}}, wantErr: true},
+		{name: "negative amount", tenders: []domain.PaymentInput{{Method: qris, Amount: -1}}, wantErr: true},
+		{name: "unknown method", tenders: []domain.PaymentInput{{Method: "cek", Amount: total}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &domain.Transaction{TotalAmount: total}
+			err := applyPayments(tx, tt.tenders)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPayment) {
+					t.Fatalf("err = %v, want ErrInvalidPayment", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx.Change != tt.wantChange || !slices.Equal(tx.Payments, tt.want) {
+				t.Errorf("change %d, payments %+v; want change %d, payments %+v", tx.Change, tx.Payments, tt.wantChange, tt.want)
+			}
+		})
+	}
+}
+
+// TestPricingOrder checks that line discounts come first, then the cart discount spread over the
+// lines, then service charge and tax on what is left, in each of the tax modes.
+func TestPricingOrder(t *testing.T) {
+	products := repository.NewProductMemoryRepo([]domain.Product{
+		{ID: 1, Nama: "Nasi Goreng", Harga: 20000, Stok: domain.Units(10), Unit: domain.DefaultUnit, Active: true, Category: domain.Category{ID: 1}},
+		{ID: 2, Nama: "Es Teh", Harga: 10000, Stok: domain.Units(10), Unit: domain.DefaultUnit, Active: true, Category: domain.Category{ID: 1}},
+	})
+	// 2 x 20.000 less 10% is 36.000, plus 10.000 makes 46.000. The 4.600 cart discount splits
+	// 3.600 / 1.000 in proportion, leaving 41.400.
+	req := domain.CheckoutRequest{
+		Items: []domain.CheckoutItem{
+			{ProductID: 1, Quantity: domain.Units(2), Discount: &domain.Discount{Type: domain.DiscountTypePercent, Value: 10}},
+			{ProductID: 2, Quantity: domain.Units(1)},
+		},
+		Discount: &domain.Discount{Type: domain.DiscountTypeFixed, Value: 4600},
+	}
+
+	tests := []struct {
+		name                     string
+		rules                    TaxRules
+		net, service, tax, total int
+	}{
+		// Service 5% of 41.400 is 2.070; tax 11% of 41.400 + 2.070 is 4.781,7.
+		{"service before tax", TaxRules{Rate: 11, ServiceChargeRate: 5}, 41400, 2070, 4782, 48252},
+		// Tax 11% of 41.400 is 4.554; service 5% of 41.400 + 4.554 is 2.297,7.
+		{"service after tax", TaxRules{Rate: 11, ServiceChargeRate: 5, ServiceChargeAfterTax: true}, 41400, 2298, 4554, 48252},
+		// 41.400 / 1,11 is 37.297 before tax, with 4.103 tax in it; service 5% is 1.864,85, whose
+		// tax of 205,15 is added.
+		{"inclusive prices", TaxRules{Rate: 11, Inclusive: true, ServiceChargeRate: 5}, 37297, 1865, 4308, 43470},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewTransactionUsecase(nil, products, nil, nil, nil, TransactionOptions{Tax: tt.rules})
+			quote, err := uc.Quote(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if quote.GrossAmount != 50000 || quote.DiscountAmount != 8600 || quote.CartDiscountAmount != 4600 {
+				t.Errorf("gross %d, discount %d, cart discount %d; want 50000, 8600, 4600",
+					quote.GrossAmount, quote.DiscountAmount, quote.CartDiscountAmount)
+			}
+			if d := quote.Details; d[0].DiscountAmount != 7600 || d[0].Subtotal != 32400 || d[1].DiscountAmount != 1000 || d[1].Subtotal != 9000 {
+				t.Errorf("lines = %d/%d and %d/%d, want discounts 7600 and 1000 leaving 32400 and 9000",
+					d[0].DiscountAmount, d[0].Subtotal, d[1].DiscountAmount, d[1].Subtotal)
+			}
+			if quote.NetAmount != tt.net || quote.ServiceChargeAmount != tt.service || quote.TaxAmount != tt.tax || quote.TotalAmount != tt.total {
+				t.Errorf("net %d, service %d, tax %d, total %d; want %d, %d, %d, %d",
+					quote.NetAmount, quote.ServiceChargeAmount, quote.TaxAmount, quote.TotalAmount, tt.net, tt.service, tt.tax, tt.total)
+			}
+		})
+	}
+}
@@ -2,40 +2,304 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
 	"kasir-api/internal/domain"
 	"kasir-api/internal/repository"
+	"maps"
+	"slices"
+	"strings"
 )
 
 // ErrCategoryNotFound is returned when a product references a non-existent category.
 var ErrCategoryNotFound = errors.New("category not found")
 
+// ErrInvalidBarcode is returned (wrapped with the barcode) when a barcode is not a valid EAN-8,
+// UPC-E, UPC-A or EAN-13 code, or is listed twice.
+var ErrInvalidBarcode = errors.New("invalid barcode")
+
+// ErrInvalidUnit is returned (wrapped with details) when a product's selling units are malformed,
+// or when a checkout line asks for a unit the product is not sold in.
+var ErrInvalidUnit = errors.New("invalid unit")
+
+// ErrInvalidVariant is returned (wrapped with details) when a product's variant attributes or a
+// variant's parent or attribute values are malformed.
+var ErrInvalidVariant = errors.New("invalid variant")
+
+// ErrInvalidBundle is returned (wrapped with details) when a bundle's components are malformed.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// ErrProductInBundle is returned (wrapped with the bundles) when deleting a product that bundles
+// still use as a component.
+var ErrProductInBundle = errors.New("product is a component of a bundle")
+
 // ProductUsecase holds business logic for products.
 type ProductUsecase struct {
-	productRepo  repository.ProductRepository
-	categoryRepo repository.CategoryRepository
+	productRepo     repository.ProductRepository
+	categoryRepo    repository.CategoryRepository
+	inStoreBarcodes []domain.InStoreBarcodeLayout
 }
 
-// NewProductUsecase creates a new product use case.
-func NewProductUsecase(productRepo repository.ProductRepository, categoryRepo repository.CategoryRepository) *ProductUsecase {
+// NewProductUsecase creates a new product use case. inStoreBarcodes are the layouts of scale labels
+// that GetByBarcode decodes.
+func NewProductUsecase(productRepo repository.ProductRepository, categoryRepo repository.CategoryRepository, inStoreBarcodes []domain.InStoreBarcodeLayout) *ProductUsecase {
 	return &ProductUsecase{
-		productRepo:  productRepo,
-		categoryRepo: categoryRepo,
+		productRepo:     productRepo,
+		categoryRepo:    categoryRepo,
+		inStoreBarcodes: inStoreBarcodes,
 	}
 }
 
-// GetAll returns all products. If name is non-empty, filters by product name (substring, case-insensitive).
-func (u *ProductUsecase) GetAll(name string) ([]domain.Product, error) {
-	return u.productRepo.GetAll(name)
+// GetAll returns the products matching f, with variants nested under their parent. If
+// f.CategoryID is set, products in its subcategories are included; ErrCategoryNotFound is returned
+// if that category does not exist.
+func (u *ProductUsecase) GetAll(f domain.ProductFilter) ([]domain.Product, error) {
+	if f.CategoryID != 0 {
+		cats, err := u.categoryRepo.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(cats, func(c domain.Category) bool { return c.ID == f.CategoryID }) {
+			return nil, ErrCategoryNotFound
+		}
+		f.CategoryIDs = domain.CategoryDescendants(cats, f.CategoryID)
+	}
+	prods, err := u.productRepo.GetAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return domain.GroupVariants(prods), nil
 }
 
-// GetByID returns a product by ID. Returns repository.ErrNotFound if not found.
+// GetByID returns a product by ID with all its variants, inactive ones included. Returns
+// repository.ErrNotFound if not found.
 func (u *ProductUsecase) GetByID(id int) (*domain.Product, error) {
-	return u.productRepo.GetByID(id)
+	p, err := u.productRepo.GetByID(id)
+	if err != nil || !p.HasVariants() {
+		return p, err
+	}
+	if p.Variants, err = u.variants(id); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// variants returns the variants of product id, inactive ones included.
+func (u *ProductUsecase) variants(id int) ([]domain.Product, error) {
+	return u.productRepo.GetAll(domain.ProductFilter{ParentID: id, IncludeInactive: true})
+}
+
+// GetByBarcode returns the product carrying barcode. For an in-store scale label it also returns
+// the quantity and amount printed on the label. Returns an error wrapping repository.ErrNotFound if
+// no product has the barcode.
+func (u *ProductUsecase) GetByBarcode(barcode string) (*domain.ScannedProduct, error) {
+	found, err := scanBarcodes(u.productRepo, u.inStoreBarcodes, []string{barcode})
+	if err != nil {
+		return nil, err
+	}
+	p, ok := found[barcode]
+	if !ok {
+		return nil, fmt.Errorf("barcode %s %w", barcode, repository.ErrNotFound)
+	}
+	return &p, nil
+}
+
+// normalizeCodes trims the SKU and barcodes of p and checks each barcode's check digit.
+func normalizeCodes(p *domain.Product) error {
+	p.SKU = strings.TrimSpace(p.SKU)
+	codes := make([]string, 0, len(p.Barcodes))
+	for _, code := range p.Barcodes {
+		code = strings.TrimSpace(code)
+		if !domain.ValidBarcode(code) {
+			return fmt.Errorf("%w: %q", ErrInvalidBarcode, code)
+		}
+		if slices.Contains(codes, code) {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidBarcode, code)
+		}
+		codes = append(codes, code)
+	}
+	p.Barcodes = codes
+	return nil
+}
+
+// normalizeUnits trims the unit names of p, defaults its base unit to domain.DefaultUnit and checks
+// that every further unit has a distinct name, a positive factor and a non-negative price.
+func normalizeUnits(p *domain.Product) error {
+	p.Unit = strings.TrimSpace(p.Unit)
+	if p.Unit == "" {
+		p.Unit = domain.DefaultUnit
+	}
+	names := []string{p.Unit}
+	for i := range p.Units {
+		u := &p.Units[i]
+		u.Name = strings.TrimSpace(u.Name)
+		switch {
+		case u.Name == "":
+			return fmt.Errorf("%w: unit name required", ErrInvalidUnit)
+		case slices.Contains(names, u.Name):
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidUnit, u.Name)
+		case u.Factor <= 0:
+			return fmt.Errorf("%w: factor of %s must be positive", ErrInvalidUnit, u.Name)
+		case u.Harga < 0:
+			return fmt.Errorf("%w: harga of %s must not be negative", ErrInvalidUnit, u.Name)
+		}
+		names = append(names, u.Name)
+	}
+	return nil
+}
+
+// normalizeVariantAttributes trims the variant attribute names of p and checks they are distinct.
+func normalizeVariantAttributes(p *domain.Product) error {
+	attrs := make([]string, 0, len(p.VariantAttributes))
+	for _, a := range p.VariantAttributes {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			return fmt.Errorf("%w: attribute name required", ErrInvalidVariant)
+		}
+		if slices.Contains(attrs, a) {
+			return fmt.Errorf("%w: attribute %s is listed twice", ErrInvalidVariant, a)
+		}
+		attrs = append(attrs, a)
+	}
+	p.VariantAttributes = attrs
+	return nil
+}
+
+// prepareVariant checks p, stored as id (0 when new), as a variant of *p.ParentID: the parent must
+// exist, have variant attributes and not be a variant itself, and p must give a value for exactly
+// those attributes, in a combination no sibling has. p takes the parent's category, and an empty
+// name is built from the parent's name and the values.
+func (u *ProductUsecase) prepareVariant(id int, p *domain.Product) error {
+	if *p.ParentID == id {
+		return fmt.Errorf("%w: a product cannot be its own variant", ErrInvalidVariant)
+	}
+	if p.HasVariants() {
+		return fmt.Errorf("%w: a variant cannot have variant attributes", ErrInvalidVariant)
+	}
+	parent, err := u.productRepo.GetByID(*p.ParentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("%w: parent product %d not found", ErrInvalidVariant, *p.ParentID)
+		}
+		return err
+	}
+	if parent.ParentID != nil || !parent.HasVariants() {
+		return fmt.Errorf("%w: product %d has no variant attributes", ErrInvalidVariant, parent.ID)
+	}
+
+	attrs := make(map[string]string, len(p.Attributes))
+	for k, v := range p.Attributes {
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !slices.Contains(parent.VariantAttributes, k) {
+			return fmt.Errorf("%w: %s is not an attribute of product %d", ErrInvalidVariant, k, parent.ID)
+		}
+		if v == "" {
+			return fmt.Errorf("%w: value of %s required", ErrInvalidVariant, k)
+		}
+		attrs[k] = v
+	}
+	for _, a := range parent.VariantAttributes {
+		if _, ok := attrs[a]; !ok {
+			return fmt.Errorf("%w: value of %s required", ErrInvalidVariant, a)
+		}
+	}
+	siblings, err := u.variants(parent.ID)
+	if err != nil {
+		return err
+	}
+	for _, s := range siblings {
+		if s.ID != id && maps.Equal(s.Attributes, attrs) {
+			return fmt.Errorf("variant %s %w", domain.VariantName(*parent, attrs), repository.ErrDuplicate)
+		}
+	}
+
+	p.Attributes = attrs
+	p.Category = parent.Category
+	if p.Nama = strings.TrimSpace(p.Nama); p.Nama == "" {
+		p.Nama = domain.VariantName(*parent, attrs)
+	}
+	return nil
+}
+
+// prepareBundle checks the components of p, stored as id (0 when new): each must be a distinct
+// product other than p that exists and is neither a bundle nor sold through variants, in a positive
+// quantity. A bundle cannot itself be a component or have variants. Component names are filled in.
+func (u *ProductUsecase) prepareBundle(id int, p *domain.Product) error {
+	if !p.IsBundle() {
+		return nil
+	}
+	if p.HasVariants() {
+		return fmt.Errorf("%w: a product with variants cannot be a bundle", ErrInvalidBundle)
+	}
+	if id != 0 {
+		users, err := u.productRepo.GetAll(domain.ProductFilter{ComponentID: id, IncludeInactive: true})
+		if err != nil {
+			return err
+		}
+		if len(users) > 0 {
+			return fmt.Errorf("%w: product %d is a component of %s", ErrInvalidBundle, id, users[0].Nama)
+		}
+	}
+	ids := make([]int, 0, len(p.Components))
+	for _, c := range p.Components {
+		switch {
+		case c.ProductID == id && id != 0:
+			return fmt.Errorf("%w: a bundle cannot contain itself", ErrInvalidBundle)
+		case slices.Contains(ids, c.ProductID):
+			return fmt.Errorf("%w: product %d is listed twice", ErrInvalidBundle, c.ProductID)
+		case c.Quantity <= 0:
+			return fmt.Errorf("%w: quantity of product %d must be positive", ErrInvalidBundle, c.ProductID)
+		}
+		ids = append(ids, c.ProductID)
+	}
+	found, err := u.productRepo.GetByIDs(ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]domain.Product, len(found))
+	for _, c := range found {
+		byID[c.ID] = c
+	}
+	for i := range p.Components {
+		c, ok := byID[p.Components[i].ProductID]
+		switch {
+		case !ok:
+			return fmt.Errorf("%w: component product %d not found", ErrInvalidBundle, p.Components[i].ProductID)
+		case c.IsBundle():
+			return fmt.Errorf("%w: %s is a bundle itself", ErrInvalidBundle, c.Nama)
+		case c.HasVariants():
+			return fmt.Errorf("%w: %s has variants; use one of them", ErrInvalidBundle, c.Nama)
+		}
+		p.Components[i].ProductName = c.Nama
+	}
+	return nil
 }
 
 // Create creates a new product. Resolves category by ID; returns error if category not found.
+// A product with ParentID is a variant of that product and takes its category instead.
+// Returns ErrInvalidBarcode for a malformed barcode, ErrInvalidUnit for malformed units,
+// ErrInvalidVariant for malformed variants, ErrInvalidBundle for malformed components and an error
+// wrapping repository.ErrDuplicate if the SKU, a barcode or a variant's attribute values are taken.
 // The repository assigns and returns the new product ID.
 func (u *ProductUsecase) Create(p domain.Product) (domain.Product, error) {
+	if err := normalizeCodes(&p); err != nil {
+		return domain.Product{}, err
+	}
+	if err := normalizeUnits(&p); err != nil {
+		return domain.Product{}, err
+	}
+	if err := u.prepareBundle(0, &p); err != nil {
+		return domain.Product{}, err
+	}
+	if p.ParentID != nil {
+		if err := u.prepareVariant(0, &p); err != nil {
+			return domain.Product{}, err
+		}
+		return u.productRepo.Create(p)
+	}
+	p.Attributes = nil
+	if err := normalizeVariantAttributes(&p); err != nil {
+		return domain.Product{}, err
+	}
 	cat, err := u.categoryRepo.GetByID(p.Category.ID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
@@ -47,12 +311,84 @@ func (u *ProductUsecase) Create(p domain.Product) (domain.Product, error) {
 	return u.productRepo.Create(p)
 }
 
-// Update updates an existing product by ID. ID and Category are preserved.
-func (u *ProductUsecase) Update(id int, p domain.Product) (domain.Product, error) {
-	return u.productRepo.Update(id, p)
+// Update updates an existing product by ID. ID and Category are preserved. SKU, barcodes, units,
+// variants and components are checked as in Create; a product's variant attributes cannot change
+// while it has variants, and a component of a bundle cannot become a bundle. p.Stok is ignored:
+// stock is set to *stock, or kept if stock is nil. A bundle's stock is never set, as it is made up
+// by its components.
+func (u *ProductUsecase) Update(id int, p domain.Product, stock *domain.Quantity) (domain.Product, error) {
+	if err := normalizeCodes(&p); err != nil {
+		return domain.Product{}, err
+	}
+	if err := normalizeUnits(&p); err != nil {
+		return domain.Product{}, err
+	}
+	if err := u.prepareBundle(id, &p); err != nil {
+		return domain.Product{}, err
+	}
+	if p.IsBundle() {
+		stock = nil
+	}
+	if p.ParentID != nil {
+		if err := u.prepareVariant(id, &p); err != nil {
+			return domain.Product{}, err
+		}
+		return u.productRepo.Update(id, p, stock)
+	}
+	p.Attributes = nil
+	if err := normalizeVariantAttributes(&p); err != nil {
+		return domain.Product{}, err
+	}
+	current, err := u.productRepo.GetByID(id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	if !slices.Equal(p.VariantAttributes, current.VariantAttributes) {
+		variants, err := u.variants(id)
+		if err != nil {
+			return domain.Product{}, err
+		}
+		if len(variants) > 0 {
+			return domain.Product{}, fmt.Errorf("%w: variant attributes cannot change while the product has variants", ErrInvalidVariant)
+		}
+	}
+	return u.productRepo.Update(id, p, stock)
 }
 
-// Delete deletes a product by ID.
+// Delete soft-deletes a product by ID together with its variants. It can be brought back with
+// Restore; variants are restored one by one. Returns ErrProductInBundle if a bundle uses the
+// product or one of its variants.
 func (u *ProductUsecase) Delete(id int) error {
+	variants, err := u.variants(id)
+	if err != nil {
+		return err
+	}
+	ids := []int{id}
+	for _, v := range variants {
+		ids = append(ids, v.ID)
+	}
+	var names []string
+	for _, pid := range ids {
+		bundles, err := u.productRepo.GetAll(domain.ProductFilter{ComponentID: pid, IncludeInactive: true})
+		if err != nil {
+			return err
+		}
+		for _, b := range bundles {
+			names = append(names, b.Nama)
+		}
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("%w: %s", ErrProductInBundle, strings.Join(names, ", "))
+	}
 	return u.productRepo.Delete(id)
 }
+
+// Restore undeletes a deleted product. Returns repository.ErrNotFound if no deleted product has that ID.
+func (u *ProductUsecase) Restore(id int) error {
+	return u.productRepo.Restore(id)
+}
+
+// Purge permanently removes deleted products that no transaction refers to.
+func (u *ProductUsecase) Purge() (int, error) {
+	return u.productRepo.Purge()
+}
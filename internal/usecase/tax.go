@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"math"
+	"slices"
+
+	"kasir-api/internal/domain"
+)
+
+// TaxRules configures tax (PPN) and service charge at checkout. Rates are percentages.
+type TaxRules struct {
+	Rate float64
+	// Inclusive means product prices already contain tax; it is extracted rather than added.
+	Inclusive bool
+	// ExemptCategories lists category IDs whose products, and those of their subcategories, are not taxed.
+	ExemptCategories  []int
+	ServiceChargeRate float64
+	// ServiceChargeAfterTax charges service on the amount including tax. Otherwise service is
+	// charged on the pre-tax amount and is itself taxed.
+	ServiceChargeAfterTax bool
+}
+
+// exempt reports whether a product is exempt from tax, given its category and the categories
+// above it: exempting a category exempts its subcategories too.
+func (r TaxRules) exempt(categoryIDs []int) bool {
+	for _, id := range r.ExemptCategories {
+		if slices.Contains(categoryIDs, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTax fills NetAmount, ServiceChargeAmount, TaxAmount and TotalAmount on a priced tx.
+// taxable is the part of the line subtotals that is subject to tax.
+//
+// Service charge is spread over taxable and exempt sales in proportion, so only the taxable
+// share of it is taxed when it is charged before tax.
+func applyTax(tx *domain.Transaction, rules TaxRules, taxable int) {
+	lines := 0
+	for _, d := range tx.Details {
+		lines += d.Subtotal
+	}
+	rate := rules.Rate / 100
+
+	// Pre-tax sales. With inclusive prices the tax inside taxable lines is extracted first.
+	net, taxableNet, lineTax := lines, taxable, 0
+	if rules.Inclusive && rate > 0 {
+		taxableNet = roundRupiah(float64(taxable) / (1 + rate))
+		lineTax = taxable - taxableNet
+		net = lines - lineTax
+	}
+
+	var service, tax int
+	if rules.ServiceChargeAfterTax {
+		tax = lineTax
+		if !rules.Inclusive {
+			tax = roundRupiah(float64(taxableNet) * rate)
+		}
+		service = roundRupiah(float64(net+tax) * rules.ServiceChargeRate / 100)
+	} else {
+		service = roundRupiah(float64(net) * rules.ServiceChargeRate / 100)
+		taxableService := 0
+		if net > 0 {
+			taxableService = roundRupiah(float64(service) * float64(taxableNet) / float64(net))
+		}
+		if rules.Inclusive {
+			tax = lineTax + roundRupiah(float64(taxableService)*rate)
+		} else {
+			tax = roundRupiah(float64(taxableNet+taxableService) * rate)
+		}
+	}
+
+	tx.TaxRate = rules.Rate
+	tx.TaxInclusive = rules.Inclusive
+	tx.ServiceChargeRate = rules.ServiceChargeRate
+	tx.NetAmount = net
+	tx.ServiceChargeAmount = service
+	tx.TaxAmount = tax
+	tx.TotalAmount = net + service + tax
+}
+
+// roundRupiah rounds a rupiah amount half away from zero.
+func roundRupiah(v float64) int {
+	return int(math.Round(v))
+}
@@ -1,18 +1,207 @@
 package usecase
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
 	"kasir-api/internal/domain"
 	"kasir-api/internal/repository"
 )
 
+// ErrIdempotencyKeyReused is returned when an Idempotency-Key is sent again with a different request body.
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// ErrIdempotencyKeyInProgress is returned when the original request for an Idempotency-Key has not finished yet.
+var ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+
+// ErrVoidWindowClosed is returned when voiding a transaction that was not made today.
+var ErrVoidWindowClosed = errors.New("transactions can only be voided on the day they were made")
+
+// TransactionOptions configures a TransactionUsecase.
+type TransactionOptions struct {
+	// MaxRetries bounds how many times an optimistic checkout is retried after losing a race
+	// with a concurrent checkout.
+	MaxRetries int
+	// IdempotencyTTL is how long an Idempotency-Key is remembered.
+	IdempotencyTTL time.Duration
+	// Tax holds the tax and service charge rules applied at checkout.
+	Tax TaxRules
+	// LowStockThreshold is the remaining stock at or below which a quote warns about low stock.
+	LowStockThreshold int
+	// InStoreBarcodes are the layouts of scale labels that checkout items may be scanned from.
+	InStoreBarcodes []domain.InStoreBarcodeLayout
+}
+
 type TransactionUsecase struct {
-	repo repository.TransactionRepository
+	repo        repository.TransactionRepository
+	products    repository.ProductRepository
+	idempotency repository.IdempotencyRepository
+	modifiers   repository.ModifierRepository
+	categories  repository.CategoryRepository
+	opts        TransactionOptions
+}
+
+// NewTransactionUsecase creates a transaction use case. Checkout prices items from products and
+// checks their modifiers against modifiers, looking up the categories above a product's category in
+// categories. modifiers and categories may be nil if no modifiers are offered.
+func NewTransactionUsecase(repo repository.TransactionRepository, products repository.ProductRepository, idempotency repository.IdempotencyRepository, modifiers repository.ModifierRepository, categories repository.CategoryRepository, opts TransactionOptions) *TransactionUsecase {
+	return &TransactionUsecase{
+		repo:        repo,
+		products:    products,
+		idempotency: idempotency,
+		modifiers:   modifiers,
+		categories:  categories,
+		opts:        opts,
+	}
+}
+
+// Checkout prices the request (discounts, tax and service charge), validates its payment and
+// creates the transaction. Inactive products are rejected with ErrProductInactive. With useLock the
+// involved products are locked for the whole write; otherwise stock is updated optimistically and
+// the write is retried up to opts.MaxRetries times on repository.ErrSerialization, which is returned
+// once retries run out.
+func (u *TransactionUsecase) Checkout(req domain.CheckoutRequest, useLock bool) (*domain.Transaction, error) {
+	return u.checkout(req, 0, "", useLock)
+}
+
+// checkout is Checkout for a request built from the cart with id cartID, or from no cart if 0.
+// If idempotencyKey is set, the transaction is stored under that reserved key as it is created.
+func (u *TransactionUsecase) checkout(req domain.CheckoutRequest, cartID int, idempotencyKey string, useLock bool) (*domain.Transaction, error) {
+	draft, products, err := u.price(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range draft.Details {
+		if p := products[d.ProductID]; !p.Active {
+			return nil, fmt.Errorf("%w: product id %d (%s)", ErrProductInactive, p.ID, p.Nama)
+		}
+	}
+	draft.CartID = cartID
+	draft.IdempotencyKey = idempotencyKey
+	if err := applyPayments(draft, req.Tenders()); err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		tx, err := u.repo.CreateTransaction(draft, useLock)
+		if err == nil || !errors.Is(err, repository.ErrSerialization) || attempt >= u.opts.MaxRetries {
+			return tx, err
+		}
+		time.Sleep(retryBackoff(attempt))
+	}
+}
+
+// CheckoutIdempotent runs Checkout at most once per key. A replay of the same request returns the
+// stored transaction with replayed set; a different request under the same key returns
+// ErrIdempotencyKeyReused. The transaction is stored under the key in the same DB transaction as
+// the sale. If the checkout fails the key is released so the client can retry it.
+func (u *TransactionUsecase) CheckoutIdempotent(key string, req domain.CheckoutRequest, useLock bool) (tx *domain.Transaction, replayed bool, err error) {
+	hash, err := requestHash(req)
+	if err != nil {
+		return nil, false, err
+	}
+	rec, reserved, err := u.idempotency.Reserve(key, hash, u.opts.IdempotencyTTL)
+	if err != nil {
+		return nil, false, err
+	}
+	if !reserved {
+		if rec.RequestHash != hash {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		if rec.Transaction == nil {
+			return nil, false, ErrIdempotencyKeyInProgress
+		}
+		return rec.Transaction, true, nil
+	}
+
+	tx, err = u.checkout(req, 0, key, useLock)
+	if err != nil {
+		if relErr := u.idempotency.Release(key); relErr != nil {
+			log.Printf("idempotency: release key %q: %v", key, relErr)
+		}
+		return nil, false, err
+	}
+	return tx, false, nil
+}
+
+// Default and maximum page sizes for List.
+const (
+	defaultTransactionPageSize = 20
+	maxTransactionPageSize     = 100
+)
+
+// List returns one page of transactions matching f. Page defaults to 1 and Limit to 20 (max 100).
+func (u *TransactionUsecase) List(f domain.TransactionFilter) (*domain.TransactionList, error) {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 {
+		f.Limit = defaultTransactionPageSize
+	}
+	if f.Limit > maxTransactionPageSize {
+		f.Limit = maxTransactionPageSize
+	}
+	txs, total, err := u.repo.ListTransactions(f)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.TransactionList{Data: txs, Page: f.Page, Limit: f.Limit, Total: total}, nil
+}
+
+// GetByID returns a transaction with its details. Returns repository.ErrNotFound if not found.
+func (u *TransactionUsecase) GetByID(id int) (*domain.Transaction, error) {
+	return u.repo.GetTransactionByID(id)
+}
+
+// GetByReceiptNumber returns a transaction with its details by receipt number. Returns
+// repository.ErrNotFound if not found.
+func (u *TransactionUsecase) GetByReceiptNumber(number string) (*domain.Transaction, error) {
+	return u.repo.GetTransactionByReceiptNumber(number)
+}
+
+// GetByReceiptToken returns a transaction with its details by receipt token. Returns
+// repository.ErrNotFound if not found.
+func (u *TransactionUsecase) GetByReceiptToken(token string) (*domain.Transaction, error) {
+	return u.repo.GetTransactionByReceiptToken(token)
+}
+
+// Void cancels a transaction made today, restoring stock for everything not yet refunded.
+// Older transactions must be refunded instead (ErrVoidWindowClosed).
+func (u *TransactionUsecase) Void(id int, req domain.VoidRequest) (*domain.Refund, error) {
+	tx, err := u.repo.GetTransactionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	created := tx.CreatedAt.In(now.Location())
+	if created.Year() != now.Year() || created.YearDay() != now.YearDay() {
+		return nil, ErrVoidWindowClosed
+	}
+	return u.repo.VoidTransaction(id, req)
+}
+
+// Refund returns the requested quantities of a transaction's detail lines and restores their stock.
+func (u *TransactionUsecase) Refund(id int, req domain.RefundRequest) (*domain.Refund, error) {
+	return u.repo.RefundTransaction(id, req)
 }
 
-func NewTransactionUsecase(repo repository.TransactionRepository) *TransactionUsecase {
-	return &TransactionUsecase{repo: repo}
+// requestHash returns a hex SHA-256 of the JSON encoding of v.
+func requestHash(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
 }
 
-func (u *TransactionUsecase) Checkout(items []domain.CheckoutItem, useLock bool) (*domain.Transaction, error) {
-	return u.repo.CreateTransaction(items)
+// retryBackoff returns a jittered delay that grows with each attempt (10ms, 20ms, 40ms, ...).
+func retryBackoff(attempt int) time.Duration {
+	base := 10 * time.Millisecond << attempt
+	return base/2 + time.Duration(rand.Int63n(int64(base)))
 }
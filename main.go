@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"kasir-api/internal/config"
 	"kasir-api/internal/handler"
+	"kasir-api/internal/receipt"
 	"kasir-api/internal/repository"
 	"kasir-api/internal/usecase"
 
@@ -37,19 +40,72 @@ func main() {
 
 	categoryRepo := repository.NewCategoryPG(pool)
 	productRepo := repository.NewProductPG(pool)
-	transactionRepo := repository.NewTransactionPG(pool)
+	transactionRepo := repository.NewTransactionPG(pool, cfg.ReceiptNumbering())
+	idempotencyRepo := repository.NewIdempotencyPG(pool)
+	cartRepo := repository.NewCartPG(pool)
+	modifierRepo := repository.NewModifierPG(pool)
 
 	// Use cases
 	categoryUC := usecase.NewCategoryUsecase(categoryRepo)
-	productUC := usecase.NewProductUsecase(productRepo, categoryRepo)
-	transactionUC := usecase.NewTransactionUsecase(transactionRepo)
+	productUC := usecase.NewProductUsecase(productRepo, categoryRepo, cfg.InStoreBarcodes)
+	modifierUC := usecase.NewModifierUsecase(modifierRepo, productRepo, categoryRepo)
+	transactionUC := usecase.NewTransactionUsecase(transactionRepo, productRepo, idempotencyRepo, modifierRepo, categoryRepo, usecase.TransactionOptions{
+		MaxRetries:     cfg.CheckoutMaxRetries,
+		IdempotencyTTL: cfg.IdempotencyTTL,
+		Tax: usecase.TaxRules{
+			Rate:                  cfg.TaxRate,
+			Inclusive:             cfg.TaxInclusive,
+			ExemptCategories:      cfg.TaxExemptCategories,
+			ServiceChargeRate:     cfg.ServiceChargeRate,
+			ServiceChargeAfterTax: cfg.ServiceChargeMode == config.ServiceChargeAfterTax,
+		},
+		LowStockThreshold: cfg.LowStockThreshold,
+		InStoreBarcodes:   cfg.InStoreBarcodes,
+	})
+	cartUC := usecase.NewCartUsecase(cartRepo, transactionUC, cfg.CartTTL, cfg.CartReserveStock)
 	reportUC := usecase.NewReportUsecase(transactionRepo)
 
+	// Receipts
+	store := receipt.Store{
+		Name:    cfg.StoreName,
+		Address: cfg.StoreAddress,
+		Phone:   cfg.StorePhone,
+		NPWP:    cfg.StoreNPWP,
+		Footer:  cfg.ReceiptFooter,
+	}
+	if cfg.ReceiptLogo != "" {
+		if store.Logo, err = receipt.LoadLogo(cfg.ReceiptLogo); err != nil {
+			log.Fatalf("receipt logo: %v", err)
+		}
+	}
+	receiptHTML, err := receipt.NewHTML(cfg.ReceiptTemplateDir)
+	if err != nil {
+		log.Fatalf("receipt template: %v", err)
+	}
+
 	// Handlers
 	categoryHandler := handler.NewCategoryHandler(categoryUC)
 	productHandler := handler.NewProductHandler(productUC)
-	transactionHandler := handler.NewTransactionHandler(transactionUC)
+	modifierHandler := handler.NewModifierHandler(modifierUC)
+	transactionHandler := handler.NewTransactionHandler(transactionUC, cfg.CheckoutUseLock())
+	cartHandler := handler.NewCartHandler(cartUC, cfg.CheckoutUseLock())
+	receiptHandler := handler.NewReceiptHandler(transactionUC, store, cfg.ReceiptPaperWidth, receiptHTML, cfg.ReceiptBaseURL)
 	reportHandler := handler.NewReportHandler(reportUC)
+	adminHandler := handler.NewAdminHandler(productUC, categoryUC, cfg.AdminToken)
+
+	// Expire abandoned carts in the background so their reserved stock is returned.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := cartUC.ExpireStale()
+			if err != nil {
+				log.Printf("carts: expire stale: %v", err)
+			} else if n > 0 {
+				log.Printf("carts: expired %d stale cart(s)", n)
+			}
+		}
+	}()
 
 	// Method not allowed response
 	methodNotAllowed := func(w http.ResponseWriter) {
@@ -70,6 +126,12 @@ func main() {
 			categoryHandler.Update(w, r)
 		case http.MethodDelete:
 			categoryHandler.Delete(w, r)
+		case http.MethodPost:
+			if !strings.HasSuffix(r.URL.Path, "/restore") {
+				http.NotFound(w, r)
+				return
+			}
+			categoryHandler.Restore(w, r)
 		default:
 			methodNotAllowed(w)
 		}
@@ -89,11 +151,21 @@ func main() {
 	http.HandleFunc("/api/products/", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
+			if r.URL.Path == "/api/products/lookup" {
+				productHandler.Lookup(w, r)
+				return
+			}
 			productHandler.GetByID(w, r)
 		case http.MethodPut:
 			productHandler.Update(w, r)
 		case http.MethodDelete:
 			productHandler.Delete(w, r)
+		case http.MethodPost:
+			if !strings.HasSuffix(r.URL.Path, "/restore") {
+				http.NotFound(w, r)
+				return
+			}
+			productHandler.Restore(w, r)
 		default:
 			methodNotAllowed(w)
 		}
@@ -109,6 +181,30 @@ func main() {
 		}
 	})
 
+	// Modifier group routes
+	http.HandleFunc("/api/modifier-groups/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			modifierHandler.GetByID(w, r)
+		case http.MethodPut:
+			modifierHandler.Update(w, r)
+		case http.MethodDelete:
+			modifierHandler.Delete(w, r)
+		default:
+			methodNotAllowed(w)
+		}
+	})
+	http.HandleFunc("/api/modifier-groups", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			modifierHandler.GetAll(w, r)
+		case http.MethodPost:
+			modifierHandler.Create(w, r)
+		default:
+			methodNotAllowed(w)
+		}
+	})
+
 	// Transaction routes
 	http.HandleFunc("/api/checkout", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -117,6 +213,94 @@ func main() {
 		}
 		transactionHandler.HandleCheckout(w, r)
 	})
+	http.HandleFunc("/api/checkout/quote", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		transactionHandler.Quote(w, r)
+	})
+
+	http.HandleFunc("/api/transactions/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			switch {
+			case r.URL.Path == "/api/transactions/lookup":
+				transactionHandler.Lookup(w, r)
+			case strings.HasSuffix(r.URL.Path, "/receipt"):
+				receiptHandler.Receipt(w, r)
+			default:
+				transactionHandler.GetByID(w, r)
+			}
+		case http.MethodPost:
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/void"):
+				transactionHandler.Void(w, r)
+			case strings.HasSuffix(r.URL.Path, "/refund"):
+				transactionHandler.Refund(w, r)
+			default:
+				http.NotFound(w, r)
+			}
+		default:
+			methodNotAllowed(w)
+		}
+	})
+	http.HandleFunc("/api/receipts/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		receiptHandler.Public(w, r)
+	})
+
+	http.HandleFunc("/api/transactions", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			transactionHandler.List(w, r)
+		default:
+			methodNotAllowed(w)
+		}
+	})
+
+	// Cart routes
+	http.HandleFunc("/api/carts/", func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		isItem := strings.Contains(path, "/items/")
+		switch {
+		case r.Method == http.MethodGet && !isItem:
+			cartHandler.GetByID(w, r)
+		case r.Method == http.MethodDelete && !isItem:
+			cartHandler.Cancel(w, r)
+		case r.Method == http.MethodPost && strings.HasSuffix(path, "/items"):
+			cartHandler.AddItem(w, r)
+		case r.Method == http.MethodPost && strings.HasSuffix(path, "/checkout"):
+			cartHandler.Checkout(w, r)
+		case r.Method == http.MethodPut && isItem:
+			cartHandler.UpdateItem(w, r)
+		case r.Method == http.MethodDelete && isItem:
+			cartHandler.RemoveItem(w, r)
+		case r.Method == http.MethodPost:
+			http.NotFound(w, r)
+		default:
+			methodNotAllowed(w)
+		}
+	})
+	http.HandleFunc("/api/carts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		cartHandler.Create(w, r)
+	})
+
+	// Admin routes
+	http.HandleFunc("/api/admin/purge", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		adminHandler.Purge(w, r)
+	})
 
 	// Report routes
 	http.HandleFunc("/api/report/hari-ini", reportHandler.HariIni)
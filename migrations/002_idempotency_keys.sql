@@ -0,0 +1,12 @@
+-- Idempotency keys for POST /api/checkout.
+-- A row is reserved (response NULL) before the checkout runs and filled in once it commits.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key            TEXT PRIMARY KEY,
+    request_hash   TEXT NOT NULL,
+    transaction_id INTEGER REFERENCES transactions(id),
+    response       JSONB,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at     TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
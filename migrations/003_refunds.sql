@@ -0,0 +1,28 @@
+-- Voids and refunds. A void cancels everything still unrefunded on a transaction;
+-- a refund returns selected quantities of individual transaction_details lines.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'completed'
+        CHECK (status IN ('completed', 'partially_refunded', 'refunded', 'voided'));
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id             SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+    type           TEXT NOT NULL CHECK (type IN ('void', 'refund')),
+    amount         INTEGER NOT NULL,
+    reason         TEXT NOT NULL,
+    performed_by   TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_transaction_id ON refunds (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_created_at ON refunds (created_at);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+    id                    SERIAL PRIMARY KEY,
+    refund_id             INTEGER NOT NULL REFERENCES refunds(id),
+    transaction_detail_id INTEGER NOT NULL REFERENCES transaction_details(id),
+    quantity              INTEGER NOT NULL CHECK (quantity > 0),
+    amount                INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_refund_items_detail_id ON refund_items (transaction_detail_id);
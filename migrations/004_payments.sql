@@ -0,0 +1,13 @@
+-- Payments recorded at checkout. amount is the part of the transaction total settled by this
+-- payment; tendered is what the customer handed over, and change_amount is returned for cash.
+CREATE TABLE IF NOT EXISTS payments (
+    id             SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+    method         TEXT NOT NULL CHECK (method IN ('cash', 'debit', 'qris', 'transfer')),
+    amount         INTEGER NOT NULL,
+    tendered       INTEGER NOT NULL,
+    change_amount  INTEGER NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_transaction_id ON payments (transaction_id);
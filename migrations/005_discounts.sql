@@ -0,0 +1,15 @@
+-- Line and cart discounts. On each line, gross_amount - discount_amount = subtotal, where
+-- discount_amount includes the line's share of the cart discount. On transactions,
+-- gross_amount - discount_amount = total_amount and cart_discount_amount is the cart-level part.
+ALTER TABLE transaction_details
+    ADD COLUMN IF NOT EXISTS gross_amount    INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS discount_amount INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS gross_amount         INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS discount_amount      INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS cart_discount_amount INTEGER NOT NULL DEFAULT 0;
+
+-- Existing rows had no discounts.
+UPDATE transaction_details SET gross_amount = subtotal WHERE gross_amount = 0;
+UPDATE transactions SET gross_amount = total_amount WHERE gross_amount = 0;
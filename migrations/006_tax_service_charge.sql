@@ -0,0 +1,12 @@
+-- Tax (PPN) and service charge. total_amount = net_amount + service_charge_amount + tax_amount,
+-- where net_amount is sales without tax. Rates are the percentages in effect at checkout.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS net_amount            INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS service_charge_amount INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_amount            INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_rate              NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_inclusive         BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS service_charge_rate   NUMERIC(5, 2) NOT NULL DEFAULT 0;
+
+-- Existing rows were untaxed.
+UPDATE transactions SET net_amount = total_amount WHERE net_amount = 0;
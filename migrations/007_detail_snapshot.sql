@@ -0,0 +1,17 @@
+-- Unit cost on products, and a snapshot of name, unit price and unit cost on each sold line so
+-- history does not change when a product is renamed or repriced.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS harga_pokok INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE transaction_details
+    ADD COLUMN IF NOT EXISTS product_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS unit_price   INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS unit_cost    INTEGER NOT NULL DEFAULT 0;
+
+-- Best effort for existing rows: current name and cost, price derived from the line itself.
+UPDATE transaction_details td
+SET product_name = p.nama,
+    unit_price   = td.gross_amount / NULLIF(td.quantity, 0),
+    unit_cost    = p.harga_pokok
+FROM products p
+WHERE p.id = td.product_id AND td.product_name = '';
@@ -0,0 +1,31 @@
+-- Parked carts (draft orders). While a cart is open and reserves stock, reserved_quantity units of
+-- each line are taken out of products.stok; they go back when the line changes, the cart expires or
+-- is cancelled, and are consumed when the cart is checked out.
+CREATE TABLE IF NOT EXISTS carts (
+    id                    SERIAL PRIMARY KEY,
+    status                TEXT NOT NULL DEFAULT 'open'
+                              CHECK (status IN ('open', 'checked_out', 'expired', 'cancelled')),
+    reserve_stock         BOOLEAN NOT NULL DEFAULT false,
+    discount_type         TEXT,
+    discount_value        INTEGER,
+    transaction_id        INTEGER REFERENCES transactions(id),
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at            TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_carts_open_expires_at ON carts (expires_at) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id                SERIAL PRIMARY KEY,
+    cart_id           INTEGER NOT NULL REFERENCES carts(id),
+    product_id        INTEGER NOT NULL REFERENCES products(id),
+    quantity          INTEGER NOT NULL CHECK (quantity > 0),
+    discount_type     TEXT,
+    discount_value    INTEGER,
+    reserved_quantity INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (cart_id, product_id)
+);
+
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS cart_id INTEGER REFERENCES carts(id);
@@ -0,0 +1,3 @@
+-- Products can be taken off sale without deleting them.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true;
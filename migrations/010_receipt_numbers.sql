@@ -0,0 +1,11 @@
+-- Human-readable receipt numbers. receipt_sequences holds one counter per outlet and reset
+-- period; it is incremented in the checkout transaction, so numbers are gap-free.
+CREATE TABLE IF NOT EXISTS receipt_sequences (
+    scope      TEXT PRIMARY KEY,
+    last_value INTEGER NOT NULL
+);
+
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS receipt_number TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_receipt_number ON transactions (receipt_number);
@@ -0,0 +1,7 @@
+-- Soft delete for products and categories. Deleted rows keep their history for past
+-- transactions until purged.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
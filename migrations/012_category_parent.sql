@@ -0,0 +1,5 @@
+-- Nested categories, e.g. Minuman > Kopi > Espresso-based. Top-level categories have no parent.
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES categories(id);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
@@ -0,0 +1,13 @@
+-- Stock keeping units and scannable barcodes. A product has at most one SKU and any number of
+-- barcodes; both are unique across products, deleted ones included so they can be restored.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku);
+
+CREATE TABLE IF NOT EXISTS product_barcodes (
+    barcode    VARCHAR(14) PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_barcodes_product_id ON product_barcodes (product_id);
@@ -0,0 +1,14 @@
+-- Fractional quantities for goods sold by weight, e.g. 0.453 kg from a scale label. Stock and every
+-- quantity that is added to or taken from it get three decimal places.
+ALTER TABLE products
+    ALTER COLUMN stok TYPE NUMERIC(12,3);
+
+ALTER TABLE transaction_details
+    ALTER COLUMN quantity TYPE NUMERIC(12,3);
+
+ALTER TABLE refund_items
+    ALTER COLUMN quantity TYPE NUMERIC(12,3);
+
+ALTER TABLE cart_items
+    ALTER COLUMN quantity TYPE NUMERIC(12,3),
+    ALTER COLUMN reserved_quantity TYPE NUMERIC(12,3);
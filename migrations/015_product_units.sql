@@ -0,0 +1,29 @@
+-- Units of measure. A product keeps its price, cost and stock in its base unit (unit) and may be sold
+-- in further units, each holding factor base units at its own price: rice per kg and per 25 kg sack,
+-- cigarettes per stick and per pack of 16.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS unit VARCHAR(20) NOT NULL DEFAULT 'pcs';
+
+CREATE TABLE IF NOT EXISTS product_units (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    name       VARCHAR(20) NOT NULL,
+    factor     NUMERIC(12,3) NOT NULL CHECK (factor > 0),
+    harga      INTEGER NOT NULL CHECK (harga >= 0),
+    PRIMARY KEY (product_id, name)
+);
+
+-- Lines record the unit they were sold in and how many base units it held at the time, so stock
+-- moves by quantity * unit_factor. Existing lines were sold in the base unit.
+ALTER TABLE transaction_details
+    ADD COLUMN IF NOT EXISTS unit VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS unit_factor NUMERIC(12,3) NOT NULL DEFAULT 1;
+
+-- A cart may hold the same product in several units; '' is the base unit. reserved_quantity stays
+-- in base units.
+ALTER TABLE cart_items
+    ADD COLUMN IF NOT EXISTS unit VARCHAR(20) NOT NULL DEFAULT '';
+
+ALTER TABLE cart_items
+    DROP CONSTRAINT IF EXISTS cart_items_cart_id_product_id_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_cart_product_unit ON cart_items (cart_id, product_id, unit);
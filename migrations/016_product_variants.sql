@@ -0,0 +1,14 @@
+-- Product variants. A parent product names its variant attributes (e.g. {ukuran,warna}) and is sold
+-- only through its variants: products of their own with parent_id set and a value for every
+-- attribute in attributes, e.g. {"ukuran": "M", "warna": "Hitam"}. Each variant has its own SKU,
+-- barcodes, price and stock.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES products(id),
+    ADD COLUMN IF NOT EXISTS variant_attributes TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_products_parent_id ON products (parent_id);
+
+-- Like SKUs, attribute combinations stay taken by deleted variants so they can be restored.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_variant_attributes ON products (parent_id, attributes)
+    WHERE parent_id IS NOT NULL;
@@ -0,0 +1,23 @@
+-- Bundles and recipe items. A bundle's bill of materials lists how much of each component, in the
+-- component's base unit, one base unit of the bundle uses. Selling a bundle takes stock from its
+-- components; the bundle's own stok is not used.
+CREATE TABLE IF NOT EXISTS product_components (
+    bundle_id    INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    component_id INT NOT NULL REFERENCES products(id),
+    quantity     NUMERIC(12,3) NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, component_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_components_component_id ON product_components (component_id);
+
+-- What each sold bundle line took from its components, so refunds return the same stock even if
+-- the bill of materials changes later, and reports can show component consumption.
+CREATE TABLE IF NOT EXISTS transaction_detail_components (
+    transaction_detail_id INT NOT NULL REFERENCES transaction_details(id) ON DELETE CASCADE,
+    product_id            INT NOT NULL REFERENCES products(id),
+    product_name          TEXT NOT NULL,
+    quantity              NUMERIC(12,3) NOT NULL,
+    PRIMARY KEY (transaction_detail_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_detail_components_product_id ON transaction_detail_components (product_id);
@@ -0,0 +1,45 @@
+-- Modifier groups such as "Shot" or "Gula" offer options that change a line's price by harga.
+-- A group applies to the products in product_ids (and their variants) and to the products of the
+-- categories in category_ids. Each line must pick between min_select and max_select options of
+-- every group that applies; max_select 0 means no limit.
+CREATE TABLE IF NOT EXISTS modifier_groups (
+    id           SERIAL PRIMARY KEY,
+    nama         VARCHAR(255) NOT NULL,
+    min_select   INT NOT NULL DEFAULT 0 CHECK (min_select >= 0),
+    max_select   INT NOT NULL DEFAULT 0 CHECK (max_select >= 0),
+    product_ids  INT[] NOT NULL DEFAULT '{}',
+    category_ids INT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_modifier_groups_product_ids ON modifier_groups USING GIN (product_ids);
+CREATE INDEX IF NOT EXISTS idx_modifier_groups_category_ids ON modifier_groups USING GIN (category_ids);
+
+CREATE TABLE IF NOT EXISTS modifiers (
+    id       SERIAL PRIMARY KEY,
+    group_id INT NOT NULL REFERENCES modifier_groups(id) ON DELETE CASCADE,
+    nama     VARCHAR(255) NOT NULL,
+    harga    INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_modifiers_group_id ON modifiers (group_id);
+
+-- The modifiers sold on each transaction line, snapshotted so receipts do not change when a group
+-- is edited or deleted. unit_price of the line already includes their harga.
+CREATE TABLE IF NOT EXISTS transaction_detail_modifiers (
+    transaction_detail_id INT NOT NULL REFERENCES transaction_details(id) ON DELETE CASCADE,
+    position              INT NOT NULL,
+    modifier_id           INT NOT NULL,
+    group_name            VARCHAR(255) NOT NULL,
+    nama                  VARCHAR(255) NOT NULL,
+    harga                 INT NOT NULL,
+    PRIMARY KEY (transaction_detail_id, position)
+);
+
+-- Cart lines remember the sorted IDs of their modifiers; the same product with other modifiers is
+-- another line.
+ALTER TABLE cart_items
+    ADD COLUMN IF NOT EXISTS modifiers INT[] NOT NULL DEFAULT '{}';
+
+DROP INDEX IF EXISTS idx_cart_items_cart_product_unit;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_cart_product_unit_modifiers ON cart_items (cart_id, product_id, unit, modifiers);
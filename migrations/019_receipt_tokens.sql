@@ -0,0 +1,6 @@
+-- Receipt links and QR codes name a transaction by receipt_token, a random value that cannot be
+-- guessed from the serial id. Existing transactions get one when the column is added.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS receipt_token TEXT NOT NULL DEFAULT replace(gen_random_uuid()::text, '-', '');
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_receipt_token ON transactions (receipt_token);